@@ -6,7 +6,8 @@ http://en.wikipedia.org/wiki/B-tree
 package BTree
 
 import (
-	"fmt"
+	"sort"
+	"time"
 )
 
 // An item inside of a btree.
@@ -19,6 +20,31 @@ type item struct {
 	value interface{}
 }
 
+// Sort the positions of a list of keys by the keys at those positions.
+// Ties are broken by the positions themselves so that items with the same
+// key stay in the order they were given, which matches what inserting
+// them one at a time would do.
+type batchOrder struct {
+	keys  []int
+	order []int
+}
+
+func (batch batchOrder) Len() int {
+	return len(batch.order)
+}
+
+func (batch batchOrder) Less(i, j int) bool {
+	a, b := batch.order[i], batch.order[j]
+	if batch.keys[a] != batch.keys[b] {
+		return batch.keys[a] < batch.keys[b]
+	}
+	return a < b
+}
+
+func (batch batchOrder) Swap(i, j int) {
+	batch.order[i], batch.order[j] = batch.order[j], batch.order[i]
+}
+
 // Internal node for the tree.
 type node struct {
 	// Metadata items.
@@ -40,6 +66,13 @@ type node struct {
 	// items in child[n+1] are all larger than it. This also implies
 	// n+1 items in the children list for n items in the items list.
 	children []*node
+
+	// Where new nodes come from and unused nodes go back to, possibly nil.
+	// This is shared by all of the nodes in a tree.
+	free *freeList
+	// Where splits, merges and changes in size are counted, or nil if the
+	// tree's metrics are not enabled. Also shared by all of the nodes.
+	metrics *Metrics
 }
 
 // The external interface to the tree.
@@ -49,20 +82,84 @@ type BTree struct {
 	// of the nodes themselves.
 	dimension int
 	root      *node
+	// Unused nodes kept for reuse, or nil to leave them to the garbage
+	// collector.
+	free *freeList
+	// Returns the current time when checking for expired items, or nil to
+	// use time.Now.
+	now func() time.Time
+	// The items added by InsertWithTTL keyed by when they expire, or nil
+	// if there have not been any.
+	expiry *BTree
+	// The tree's counters, or nil until EnableMetrics is called.
+	metrics *Metrics
 }
 
 // Create a new BTree with the given dimension.
 func NewBTree(dimension int) *BTree {
 	// Note that the root starts off as a leaf.
-	rootNode := &node{true, 2 * dimension, 0, nil, make([]item, 2*dimension+1), nil}
-	tree := &BTree{dimension, rootNode}
+	rootNode := &node{true, 2 * dimension, 0, nil, make([]item, 2*dimension+1), nil, nil, nil}
+	tree := &BTree{dimension, rootNode, nil, nil, nil, nil}
 	return tree
 }
 
+// Create a new BTree with the given dimension which keeps up to
+// freeListSize unused nodes around for reuse. Nodes are discarded when
+// removals merge them together, and allocated when insertions split them,
+// so under a mix of the two this saves allocating (and garbage collecting)
+// a node and its slices on most splits.
+func NewBTreeWithFreeList(dimension int, freeListSize int) *BTree {
+	free := newFreeList(freeListSize)
+	return &BTree{dimension, free.newNode(true, 2*dimension, nil), free, nil, nil, nil}
+}
+
+// Create a new BTree with the given dimension holding the given items,
+// which must already be in sorted order. This is much cheaper than
+// inserting the items one at a time.
+func newBTreeFromItems(dimension int, items []item) *BTree {
+	return &BTree{dimension, bulkLoad(dimension, items, nil), nil, nil, nil, nil}
+}
+
 // Add a key value pair into the tree.
 func (tree *BTree) Insert(key int, value interface{}) {
-	fmt.Println("Adding value", key, "to tree.")
+	start := tree.metrics.start()
 	tree.root.insert(item{key, value}, nil)
+	tree.metrics.finish(insertOperation, start)
+}
+
+// Add many key value pairs into the tree at once. The pairs are sorted by
+// key first so that each run of keys which belongs in the same leaf can be
+// added with a single descent from the root. The keys and values slices
+// must have the same length.
+func (tree *BTree) InsertBatch(keys []int, values []interface{}) {
+	if len(keys) != len(values) {
+		panic("BTree: InsertBatch called with different numbers of keys and values")
+	}
+	// Sort the positions of the keys rather than the items themselves,
+	// since moving ints around is much cheaper.
+	order := make([]int, len(keys))
+	for i := range order {
+		order[i] = i
+	}
+	if !sort.IsSorted(batchOrder{keys, order}) {
+		sort.Sort(batchOrder{keys, order})
+	}
+	batch := make([]item, len(keys))
+	for i, position := range order {
+		batch[i] = item{keys[position], values[position]}
+	}
+
+	tree.metrics.count(insertOperation, len(batch))
+	// An empty tree can be built directly from the sorted items.
+	if tree.root.isLeaf && tree.root.currentSize == 0 {
+		tree.free.freeNode(tree.root)
+		tree.root = bulkLoad(tree.dimension, batch, tree.free)
+		tree.metrics.attach(tree.root)
+		return
+	}
+	for len(batch) > 0 {
+		batch = tree.root.insertRun(batch)
+	}
 }
 
 // Determine the number of items in the tree.
@@ -78,20 +175,102 @@ func (tree *BTree) Depth() int {
 // Find the value of the first item in the tree with the same
 // key. If there are multiple items with the same key, the first
 // found will be returned. If the key is not found, nil will be
-// returned.
+// returned. Items added by InsertWithTTL which have expired are skipped.
 func (tree *BTree) Search(key int) interface{} {
-	return tree.root.search(key)
+	start := tree.metrics.start()
+	defer tree.metrics.finish(searchOperation, start)
+	value := tree.root.search(key)
+	if _, ok := value.(*expiring); ok {
+		return tree.searchLive(key)
+	}
+	return value
+}
+
+// Call fn for each item in the tree in sorted order. Stops early if fn
+// returns false.
+func (tree *BTree) Ascend(fn func(key int, value interface{}) bool) {
+	it := newIterator(tree.root)
+	for next, ok := it.next(); ok; next, ok = it.next() {
+		if value, live := tree.unwrap(next.value); live && !fn(next.key, value) {
+			return
+		}
+	}
+}
+
+// Call fn for each item in the tree with a key in the range [start, end),
+// in sorted order. Stops early if fn returns false.
+func (tree *BTree) AscendRange(start, end int, fn func(key int, value interface{}) bool) {
+	it := newIteratorFrom(tree.root, start)
+	for next, ok := it.next(); ok && next.key < end; next, ok = it.next() {
+		if value, live := tree.unwrap(next.value); live && !fn(next.key, value) {
+			return
+		}
+	}
 }
 
+// Remove the first item in the tree with the given key, which is the same
+// item that Search would find, and return its value. If the key is not
+// found, nil will be returned. Expired items with the key which are found
+// first are removed along the way.
 func (tree *BTree) Remove(key int) interface{} {
-	return tree.root.remove(key)
+	start := tree.metrics.start()
+	defer tree.metrics.finish(removeOperation, start)
+	stored, ok := tree.removeFirst(key)
+	if !ok {
+		return nil
+	}
+	value, _ := tree.unwrap(stored)
+	return value
+}
+
+// Remove the first item with the given key which has not expired, as
+// Remove does, and return its value as it is stored in the tree. Returns
+// false if there is no such item.
+func (tree *BTree) removeFirst(key int) (interface{}, bool) {
+	for {
+		found, i := tree.root.find(key)
+		if found == nil {
+			return nil, false
+		}
+		stored := found.items[i].value
+		_, live := tree.unwrap(stored)
+		tree.removeItemAt(found, i)
+		if live {
+			return stored, true
+		}
+	}
+}
+
+// Remove the item with the key whose stored value is the given one, which
+// may be a different item than Remove would pick when there are several
+// with the key, and whether or not it has expired. Returns false if it is
+// not found.
+func (tree *BTree) removeExact(key int, stored interface{}) bool {
+	start := tree.metrics.start()
+	defer tree.metrics.finish(removeOperation, start)
+	found, i := tree.root.findMatching(key, stored)
+	if found == nil {
+		return false
+	}
+	tree.removeItemAt(found, i)
+	return true
+}
+
+// Remove the item at the given index of the node, along with its entry in
+// the expiry index if it has one.
+func (tree *BTree) removeItemAt(found *node, i int) {
+	// A transaction's copy of a tree with ttls holds its entries without
+	// an expiry index.
+	if entry, ok := found.items[i].value.(*expiring); ok && tree.expiry != nil {
+		tree.expiry.removeMatching(entry.deadline, entry)
+	}
+	found.removeAt(i)
 }
 
 // Function to insert an item into a node.
 // This function may call recursively into its child nodes to find the
 // correct location.
 func (node *node) insert(value item, child *node) {
-	fmt.Println("Adding value", value, "child:", child, "to node", node)
 	// If this node is a leaf, then clearly we need to insert into the list.
 	// If there is a child pointer, then insert as well since this is
 	// probably coming back up the tree from a node splitting.
@@ -119,6 +298,38 @@ func (node *node) insert(value item, child *node) {
 	}
 }
 
+// Insert a sorted run of items, starting with the first one. This finds
+// the leaf for the first item and then keeps adding items to that leaf for
+// as long as they belong there too, splitting it at most once. Returns the
+// items which have not been added yet.
+func (node *node) insertRun(batch []item) []item {
+	// The largest key which still belongs in the leaf, if there is one.
+	limit, hasLimit := 0, false
+	for !node.isLeaf {
+		// Use the same child as node.insert() would.
+		i := 0
+		for i < node.currentSize && batch[0].key > node.items[i].key {
+			i++
+		}
+		if i < node.currentSize {
+			limit, hasLimit = node.items[i].key, true
+		}
+		node = node.children[i]
+	}
+
+	for len(batch) > 0 && (!hasLimit || batch[0].key <= limit) {
+		node.insertItemIntoNode(batch[0], nil)
+		batch = batch[1:]
+		// After a split the rest of the run may belong in the new node,
+		// so go back to the top and find out.
+		if node.currentSize > node.maxSize {
+			node.splitNode()
+			break
+		}
+	}
+	return batch
+}
+
 // Insert the item into the current node.
 // This differs from the node.insert() function above in that here we
 // always add to the current items list and do not worry about splitting.
@@ -143,6 +354,28 @@ func (node *node) insertItemIntoNode(value item, child *node) {
 		node.children[node.currentSize+1] = child
 	}
 	node.currentSize += 1
+	node.metrics.resize(1)
+}
+
+// Insert an item which was pushed up by splitting one of the children of
+// this node, along with the new node holding the right half of the split.
+// Unlike insertItemIntoNode(), this places the item by the position of the
+// child rather than by its key, which matters when several items share
+// the same key. This node may need to split in turn.
+func (node *node) insertAfterChild(left *node, value item, right *node) {
+	i := 0
+	for node.children[i] != left {
+		i++
+	}
+	copy(node.items[i+1:node.currentSize+1], node.items[i:node.currentSize])
+	copy(node.children[i+2:node.currentSize+2], node.children[i+1:node.currentSize+1])
+	node.items[i] = value
+	node.children[i+1] = right
+	right.parent = node
+	node.currentSize++
+	if node.currentSize > node.maxSize {
+		node.splitNode()
+	}
 }
 
 // Split a node which has too many items - ie currentSize is larger
@@ -152,13 +385,11 @@ func (node *node) insertItemIntoNode(value item, child *node) {
 // handled by the insertion code). In the case of the root node splitting,
 // that must be handled specially.
 func (currentNode *node) splitNode() {
-	fmt.Println("Splitting:", currentNode)
 	// Create a new node for half of these children.
-	rightNode := &node{true, currentNode.maxSize, 0, currentNode.parent,
-		make([]item, len(currentNode.items)), nil}
-	if currentNode.children != nil {
-		rightNode.children = make([]*node, 1+cap(currentNode.items))
-	}
+	rightNode := currentNode.free.newNode(currentNode.children == nil,
+		currentNode.maxSize, currentNode.parent)
+	rightNode.metrics = currentNode.metrics
+	currentNode.metrics.split(currentNode.parent == nil)
 
 	// The median node for the data in this node.
 	middleIndex := len(currentNode.items) / 2
@@ -188,15 +419,12 @@ func (currentNode *node) splitNode() {
 	// to keep pointers to this node correct, but move half of the children
 	// into a new left node.
 	if currentNode.parent != nil {
-		currentNode.parent.insert(median, rightNode)
+		currentNode.parent.insertAfterChild(currentNode, median, rightNode)
 		return
 	} else {
-		leftNode := &node{true, currentNode.maxSize, 0, currentNode,
-			make([]item, len(currentNode.items)), nil}
-		if currentNode.children != nil {
-			leftNode.isLeaf = false
-			leftNode.children = make([]*node, 1+cap(currentNode.items))
-		}
+		leftNode := currentNode.free.newNode(currentNode.children == nil,
+			currentNode.maxSize, currentNode)
+		leftNode.metrics = currentNode.metrics
 
 		for i := 0; i < middleIndex; i++ {
 			leftNode.items[i] = currentNode.items[i]
@@ -218,7 +446,7 @@ func (currentNode *node) splitNode() {
 		// This node is no longer a leaf.
 		if currentNode.isLeaf {
 			currentNode.isLeaf = false
-			currentNode.children = make([]*node, 1+cap(currentNode.items))
+			currentNode.children = currentNode.free.newChildren(currentNode.maxSize)
 		}
 		//
 		rightNode.parent = currentNode
@@ -227,15 +455,125 @@ func (currentNode *node) splitNode() {
 	}
 }
 
+// Build a tree from a list of items which are already in sorted order and
+// return its root. Rather than inserting the items one at a time, the
+// leaves are filled from left to right. Whenever the rightmost node at a
+// level is full, the next item is pushed up as a separator into the level
+// above and a new node is started, much like a split would do.
+func bulkLoad(dimension int, items []item, free *freeList) *node {
+	maxSize := 2 * dimension
+	// The rightmost node at each level of the tree, starting at the leaves.
+	levels := []*node{free.newNode(true, maxSize, nil)}
+	for _, value := range items {
+		// The node to the right of value, if it is a separator.
+		var child *node
+		for level := 0; ; level++ {
+			if level == len(levels) {
+				// The top node is full, so add a new root above it.
+				root := free.newNode(false, maxSize, nil)
+				root.children[0] = levels[level-1]
+				root.children[0].parent = root
+				levels = append(levels, root)
+			}
+			current := levels[level]
+			if current.currentSize < maxSize {
+				current.items[current.currentSize] = value
+				if child != nil {
+					current.children[current.currentSize+1] = child
+					child.parent = current
+					levels[level-1] = child
+				}
+				current.currentSize++
+				break
+			}
+			// This node is full - start a new one and push the value up
+			// to separate the two.
+			next := free.newNode(child == nil, maxSize, nil)
+			if child != nil {
+				next.children[0] = child
+				child.parent = next
+				levels[level-1] = child
+			}
+			child = next
+		}
+	}
+
+	// Every node other than the rightmost one at each level is full, but
+	// the rightmost ones may have ended up with too few items (or even no
+	// items if they have just been started). Fix that by moving items over
+	// from the full left sibling, working down from the top.
+	for level := len(levels) - 2; level >= 0; level-- {
+		right := levels[level]
+		parent := right.parent
+		for right.currentSize < dimension {
+			parent.rotateRight(parent.currentSize - 1)
+		}
+	}
+	return levels[len(levels)-1]
+}
+
+// Move the last item out of children[i] up into this node, and move the
+// separator at items[i] down to the front of children[i+1]. For internal
+// nodes the last child of children[i] moves along with it.
+func (parent *node) rotateRight(i int) {
+	left, right := parent.children[i], parent.children[i+1]
+
+	// Make room at the front of the right node.
+	copy(right.items[1:right.currentSize+1], right.items[:right.currentSize])
+	right.items[0] = parent.items[i]
+	if !right.isLeaf {
+		copy(right.children[1:right.currentSize+2], right.children[:right.currentSize+1])
+		right.children[0] = left.children[left.currentSize]
+		right.children[0].parent = right
+		left.children[left.currentSize] = nil
+	}
+	right.currentSize++
+
+	parent.items[i] = left.items[left.currentSize-1]
+	left.items[left.currentSize-1] = item{0, nil}
+	left.currentSize--
+}
+
+// Move the first item out of children[i+1] up into this node, and move
+// the separator at items[i] down to the end of children[i]. For internal
+// nodes the first child of children[i+1] moves along with it.
+func (parent *node) rotateLeft(i int) {
+	left, right := parent.children[i], parent.children[i+1]
+
+	left.items[left.currentSize] = parent.items[i]
+	if !left.isLeaf {
+		left.children[left.currentSize+1] = right.children[0]
+		left.children[left.currentSize+1].parent = left
+		copy(right.children[:right.currentSize], right.children[1:right.currentSize+1])
+		right.children[right.currentSize] = nil
+	}
+	left.currentSize++
+
+	parent.items[i] = right.items[0]
+	copy(right.items[:right.currentSize-1], right.items[1:right.currentSize])
+	right.currentSize--
+	right.items[right.currentSize] = item{0, nil}
+}
+
 func (n *node) search(key int) interface{} {
-	fmt.Println("Searching for", key, "in", n)
+	if found, i := n.find(key); found != nil {
+		return found.items[i].value
+	}
+	// The item is not in the tree.
+	return nil
+}
+
+// Find the first item with the key below this node. Returns the node
+// holding the item and its index in that node, or nil if the key is not
+// found.
+func (n *node) find(key int) (*node, int) {
 	if n.isLeaf {
 		// If we are at a leaf node, search through the items list
 		// until the end or we have found a key which is larger
 		// than the search key.
 		for i := 0; i < n.currentSize && key >= n.items[i].key; i++ {
 			if n.items[i].key == key {
-				return n.items[i].value
+				return n, i
 			}
 		}
 	} else {
@@ -244,16 +582,15 @@ func (n *node) search(key int) interface{} {
 		// the data is in the matching child node.
 		for i := 0; i < n.currentSize; i++ {
 			if key == n.items[i].key {
-				return n.items[i].value
+				return n, i
 			}
 			if key < n.items[i].key {
-				return n.children[i].search(key)
+				return n.children[i].find(key)
 			}
 		}
-		return n.children[n.currentSize].search(key)
+		return n.children[n.currentSize].find(key)
 	}
-	// The item is not in the tree.
-	return nil
+	return nil, 0
 }
 
 // Determine the total size of the tree below this node, including the
@@ -262,7 +599,6 @@ func (n *node) search(key int) interface{} {
 // way for fun.
 func (node *node) size() int {
 	totalSize := node.currentSize
-	fmt.Println(node)
 	if !node.isLeaf {
 		for i := 0; i < node.currentSize; i++ {
 			totalSize += node.children[i].size()
@@ -304,29 +640,178 @@ func (node *node) keyTraversal() []int {
 	return results
 }
 
-func (node *node) remove(key int) interface{} {
-	if node.isLeaf {
-		matchedValue := nil;
-		readPointer := 0
-		for i := 0; i < node.currentSize; i++ {
-			node.items[readPointer] = node.items[i]
-			if key == node.items[i].key && matchedValue == nil {
-				matchedValue = node.items[i].value;
-				node.currentSize--
-			} else {
-				readPointer++
+// An in-order cursor over the items below a node.
+type iterator struct {
+	// The path from the top node down to the current position, along with
+	// the index of the next item to return from each node on the path.
+	nodes   []*node
+	indexes []int
+}
+
+// Create an iterator positioned before the first item below the node.
+func newIterator(top *node) *iterator {
+	it := &iterator{}
+	it.descend(top)
+	return it
+}
+
+// Create an iterator positioned before the first item below the node with
+// a key which is not smaller than the given key.
+func newIteratorFrom(top *node, key int) *iterator {
+	it := &iterator{}
+	for n := top; ; n = n.children[it.indexes[len(it.indexes)-1]] {
+		// Everything before the first item which is not smaller than the
+		// key, including the children to the left of it, is skipped.
+		i := 0
+		for i < n.currentSize && n.items[i].key < key {
+			i++
+		}
+		it.nodes = append(it.nodes, n)
+		it.indexes = append(it.indexes, i)
+		if n.isLeaf {
+			return it
+		}
+	}
+}
+
+// Add the path down to the leftmost leaf below the given node.
+func (it *iterator) descend(n *node) {
+	for {
+		it.nodes = append(it.nodes, n)
+		it.indexes = append(it.indexes, 0)
+		if n.isLeaf {
+			return
+		}
+		n = n.children[0]
+	}
+}
+
+// Return the next item in sorted order, or false once all of the items
+// have been returned.
+func (it *iterator) next() (item, bool) {
+	for len(it.nodes) > 0 {
+		last := len(it.nodes) - 1
+		n, i := it.nodes[last], it.indexes[last]
+		if i < n.currentSize {
+			it.indexes[last]++
+			// Everything in the child to the right of this item comes
+			// after it.
+			if !n.isLeaf {
+				it.descend(n.children[i+1])
 			}
+			return n.items[i], true
 		}
-		if matchedValue {
-			node.items[node.currentSize] = item{0, nil}
+		// Done with this node, so pop back up to the parent.
+		it.nodes = it.nodes[:last]
+		it.indexes = it.indexes[:last]
+	}
+	return item{}, false
+}
+
+// Copy this node and everything below it, so that the copy has exactly
+// the same shape and finds the same items. The copy does not share the
+// free list or metrics.
+func (n *node) clone(parent *node) *node {
+	c := &node{n.isLeaf, n.maxSize, n.currentSize, parent, make([]item, len(n.items)), nil, nil, nil}
+	copy(c.items, n.items)
+	if !n.isLeaf {
+		c.children = make([]*node, len(n.children))
+		for i := 0; i <= n.currentSize; i++ {
+			c.children[i] = n.children[i].clone(c)
 		}
-		return matchedValue
-	} else {
-		for i := 0; i < node.currentSize; i++ {
-			if key <= node.items[i].key {
-				return node.children[i].remove(key)
+	}
+	return c
+}
+
+// Remove the item at the given index of this node. An item in an internal
+// node is replaced by the largest item in the child to its left, which is
+// always in a leaf, so it is always a leaf which loses an item. That leaf
+// is then rebalanced if it has ended up with too few items.
+func (node *node) removeAt(i int) {
+	if !node.isLeaf {
+		leaf := node.children[i]
+		for !leaf.isLeaf {
+			leaf = leaf.children[leaf.currentSize]
+		}
+		node.items[i] = leaf.items[leaf.currentSize-1]
+		node, i = leaf, leaf.currentSize-1
+	}
+	copy(node.items[i:node.currentSize-1], node.items[i+1:node.currentSize])
+	node.currentSize--
+	node.items[node.currentSize] = item{0, nil}
+	node.metrics.resize(-1)
+	node.rebalance()
+}
+
+// Fix up a node which may have too few items after a removal. Every node
+// other than the root should be at least half full, so if this one is not
+// then it either borrows an item from one of its siblings or, if they
+// have none to spare, is merged with one of them. Merging takes an item
+// from the parent, so the parent may then need rebalancing too.
+func (node *node) rebalance() {
+	parent := node.parent
+	if parent == nil {
+		// The root may have any number of items, but once it has none left
+		// its only child takes its place. The contents of the child are
+		// moved up rather than replacing the root node, which keeps the
+		// tree's pointer to the root valid (as in splitNode).
+		if !node.isLeaf && node.currentSize == 0 {
+			child := node.children[0]
+			node.isLeaf = child.isLeaf
+			node.currentSize = child.currentSize
+			node.items, child.items = child.items, node.items
+			node.children, child.children = child.children, node.children
+			for i := 0; !node.isLeaf && i <= node.currentSize; i++ {
+				node.children[i].parent = node
 			}
+			// The child is left holding the old slices of the root.
+			node.free.freeNode(child)
+			node.metrics.collapse()
+		}
+		return
+	}
+	minSize := node.maxSize / 2
+	if node.currentSize >= minSize {
+		return
+	}
+
+	i := 0
+	for parent.children[i] != node {
+		i++
+	}
+	if i > 0 && parent.children[i-1].currentSize > minSize {
+		parent.rotateRight(i - 1)
+	} else if i < parent.currentSize && parent.children[i+1].currentSize > minSize {
+		parent.rotateLeft(i)
+	} else if i > 0 {
+		parent.mergeChildren(i - 1)
+	} else {
+		parent.mergeChildren(i)
+	}
+}
+
+// Merge children[i+1] into children[i], along with the item separating
+// them in this node. The caller must make sure that the result fits.
+func (parent *node) mergeChildren(i int) {
+	left, right := parent.children[i], parent.children[i+1]
+
+	left.items[left.currentSize] = parent.items[i]
+	copy(left.items[left.currentSize+1:], right.items[:right.currentSize])
+	if !left.isLeaf {
+		copy(left.children[left.currentSize+1:], right.children[:right.currentSize+1])
+		for j := left.currentSize + 1; j <= left.currentSize+1+right.currentSize; j++ {
+			left.children[j].parent = left
 		}
-		return node.children[node.currentSize].remove(key)
 	}
-}
\ No newline at end of file
+	left.currentSize += 1 + right.currentSize
+
+	// Drop the separator and the right child from this node.
+	copy(parent.items[i:parent.currentSize-1], parent.items[i+1:parent.currentSize])
+	copy(parent.children[i+1:parent.currentSize], parent.children[i+2:parent.currentSize+1])
+	parent.children[parent.currentSize] = nil
+	parent.currentSize--
+	parent.items[parent.currentSize] = item{0, nil}
+	parent.free.freeNode(right)
+	parent.metrics.merge()
+	parent.rebalance()
+}
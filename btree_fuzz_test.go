@@ -0,0 +1,171 @@
+package BTree
+
+import (
+	"sort"
+	"testing"
+)
+
+// A simple reference model of the tree: a sorted list of items.
+type sortedModel struct {
+	items []item
+}
+
+// Add an item after any others with the same key.
+func (m *sortedModel) insert(key int, value interface{}) {
+	i := sort.Search(len(m.items), func(i int) bool { return m.items[i].key > key })
+	m.items = append(m.items, item{})
+	copy(m.items[i+1:], m.items[i:])
+	m.items[i] = item{key, value}
+}
+
+// The values of all of the items with the key.
+func (m *sortedModel) values(key int) []interface{} {
+	values := make([]interface{}, 0)
+	for _, next := range m.items {
+		if next.key == key {
+			values = append(values, next.value)
+		}
+	}
+	return values
+}
+
+// Remove the item with the key and value, returning false if there isn't one.
+func (m *sortedModel) remove(key int, value interface{}) bool {
+	for i, next := range m.items {
+		if next.key == key && next.value == value {
+			m.items = append(m.items[:i], m.items[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// The items with keys in the range [start, end).
+func (m *sortedModel) ascendRange(start, end int) []item {
+	results := make([]item, 0)
+	for _, next := range m.items {
+		if next.key >= start && next.key < end {
+			results = append(results, next)
+		}
+	}
+	return results
+}
+
+// Does the list of values contain the value?
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Sort items by key and then by value, for comparing lists of items where
+// the order of items with the same key doesn't matter. The values must
+// all be ints.
+func sortItems(items []item) {
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].key != items[j].key {
+			return items[i].key < items[j].key
+		}
+		return items[i].value.(int) < items[j].value.(int)
+	})
+}
+
+// The operations decoded from the fuzz input.
+const (
+	fuzzInsert = iota
+	fuzzRemove
+	fuzzSearch
+	fuzzRange
+	numFuzzOps
+)
+
+// Apply a sequence of operations to both a tree and the reference model,
+// checking that they agree and that the tree is well formed after each
+// step. The first byte picks the dimension of the tree and whether it
+// reuses nodes through a free list, then every three bytes are an
+// operation, a key and (for range scans) a length. Keys are kept to a
+// small range so that there are plenty of duplicates.
+//
+// Search and Remove only promise to find some item with the key when
+// there are duplicates, so every value is unique and those results are
+// checked against all of the values with the key.
+func FuzzOperations(f *testing.F) {
+	f.Add([]byte{1, 0, 5, 0, 0, 3, 0, 0, 8, 0, 2, 3, 0, 1, 5, 0, 3, 0, 10})
+	f.Add([]byte{0, 0, 1, 0, 0, 2, 0, 0, 3, 0, 0, 4, 0, 0, 5, 0, 1, 3, 0, 1, 1, 0, 3, 0, 9})
+	f.Add([]byte{10, 0, 7, 0, 0, 7, 0, 0, 7, 0, 0, 7, 0, 0, 7, 0, 1, 7, 0, 2, 7, 0, 1, 7, 0})
+	seed := []byte{3}
+	for i := 0; i < 100; i++ {
+		seed = append(seed, byte(i%numFuzzOps), byte(i*37), byte(i))
+	}
+	f.Add(seed)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if len(data) == 0 {
+			return
+		}
+		tree := NewBTree(1 + int(data[0])%4)
+		if data[0]&8 != 0 {
+			tree = NewBTreeWithFreeList(1+int(data[0])%4, 4)
+		}
+		model := &sortedModel{}
+		for step := 1; step+2 < len(data); step += 3 {
+			op, key, length := int(data[step])%numFuzzOps, int(int8(data[step+1]))/4, int(data[step+2])
+			switch op {
+			case fuzzInsert:
+				tree.Insert(key, step)
+				model.insert(key, step)
+			case fuzzRemove:
+				value := tree.Remove(key)
+				if value == nil {
+					if len(model.values(key)) != 0 {
+						t.Fatal("Remove did not find key", key, "at step", step)
+					}
+				} else if !model.remove(key, value) {
+					t.Fatal("Remove returned the wrong value for key", key, value, "at step", step)
+				}
+			case fuzzSearch:
+				value := tree.Search(key)
+				values := model.values(key)
+				if value == nil && len(values) != 0 {
+					t.Fatal("Search did not find key", key, "at step", step)
+				}
+				if value != nil && !containsValue(values, value) {
+					t.Fatal("Search returned the wrong value for key", key, value, "at step", step)
+				}
+			case fuzzRange:
+				found := make([]item, 0)
+				tree.AscendRange(key, key+length, func(key int, value interface{}) bool {
+					found = append(found, item{key, value})
+					return true
+				})
+				expected := model.ascendRange(key, key+length)
+				for i := 1; i < len(found); i++ {
+					if found[i-1].key > found[i].key {
+						t.Fatal("AscendRange is out of order:", found, "at step", step)
+					}
+				}
+				sortItems(found)
+				sortItems(expected)
+				if len(found) != len(expected) {
+					t.Fatal("AscendRange found the wrong items:", found, expected, "at step", step)
+				}
+				for i := range found {
+					if found[i] != expected[i] {
+						t.Fatal("AscendRange found the wrong items:", found, expected, "at step", step)
+					}
+				}
+			}
+
+			if tree.Size() != len(model.items) {
+				t.Fatal("tree has the wrong size:", tree.Size(), len(model.items), "at step", step)
+			}
+			checkNode(t, tree.root, true)
+			if t.Failed() {
+				t.Fatal("tree is broken after step", step, "\n", tree)
+			}
+		}
+	})
+}
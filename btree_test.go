@@ -76,12 +76,12 @@ func Test_AddAFewElementsNoSplitting(t *testing.T) {
 func Test_InsertWithChildren(t *testing.T) {
 	// The parent node for the tree. Set the initial size to 1 since
 	// we setup these manually.
-	root := node{false, 5, 1, nil, make([]item, 5), make([]*node, 5)}
+	root := node{false, 5, 1, nil, make([]item, 5), make([]*node, 5), nil, nil}
 	// Start it off with some initial data.
 	root.items[0] = item{0, "initial"}
-	root.children[0] = &node{true, 5, 0, nil, make([]item, 5), nil}
+	root.children[0] = &node{true, 5, 0, nil, make([]item, 5), nil, nil, nil}
 	root.children[0].insert(item{-1, "left child"}, nil)
-	root.children[1] = &node{true, 5, 0, nil, make([]item, 5), nil}
+	root.children[1] = &node{true, 5, 0, nil, make([]item, 5), nil, nil, nil}
 	root.children[1].insert(item{1, "right child"}, nil)
 	if root.children[1].size() != 1 {
 		t.Error("wrong total size", root.children[1])
@@ -90,7 +90,7 @@ func Test_InsertWithChildren(t *testing.T) {
 		t.Error("wrong total size", root)
 	}
 
-	lowNode := &node{true, 5, 0, nil, make([]item, 5), nil}
+	lowNode := &node{true, 5, 0, nil, make([]item, 5), nil, nil, nil}
 	lowNode.insert(item{3, "new right child"}, nil)
 	root.insert(item{2, "foo"}, lowNode)
 	if root.currentSize != 2 {
@@ -109,7 +109,7 @@ func Test_InsertWithChildren(t *testing.T) {
 		t.Error("wrong third child", root.children)
 	}
 
-	highNode := &node{true, 5, 0, nil, make([]item, 5), nil}
+	highNode := &node{true, 5, 0, nil, make([]item, 5), nil, nil, nil}
 	highNode.insert(item{12, "high right child"}, nil)
 	root.insert(item{10, "bar"}, highNode)
 	if root.currentSize != 3 {
@@ -122,7 +122,7 @@ func Test_InsertWithChildren(t *testing.T) {
 		t.Error("wrong fourth child", root.children)
 	}
 
-	midNode := &node{true, 5, 0, nil, make([]item, 5), nil}
+	midNode := &node{true, 5, 0, nil, make([]item, 5), nil, nil, nil}
 	midNode.insert(item{7, "mid right child"}, nil)
 	root.insert(item{5, "baz"}, midNode)
 	if root.currentSize != 4 {
@@ -139,14 +139,14 @@ func Test_InsertWithChildren(t *testing.T) {
 // Test splitting a node when the parent node has enough space such that
 // further splitting is not required.
 func Test_SplitNoParentHasRoom(t *testing.T) {
-	root := node{false, 5, 1, nil, make([]item, 6), make([]*node, 7)}
+	root := node{false, 5, 1, nil, make([]item, 6), make([]*node, 7), nil, nil}
 	// Start it off with some initial data.
 	root.items[0] = item{0, "initial"}
-	root.children[0] = &node{true, 3, 0, nil, make([]item, 4), nil}
+	root.children[0] = &node{true, 3, 0, nil, make([]item, 4), nil, nil, nil}
 	root.children[0].parent = &root
 	root.children[0].insert(item{-1, "left child"}, nil)
 
-	root.children[1] = &node{true, 3, 0, nil, make([]item, 4), nil}
+	root.children[1] = &node{true, 3, 0, nil, make([]item, 4), nil, nil, nil}
 	root.children[1].parent = &root
 	root.children[1].insert(item{1, "right child"}, nil)
 
@@ -381,3 +381,435 @@ func Test_AddManyRandom(t *testing.T) {
 		t.Error("Weird tree depth:", tree.Depth())
 	}
 }
+
+// Check the structure of the tree below the node: items are sorted and
+// fall between the separators in the parent, nodes have sensible sizes,
+// parent pointers are right and all of the leaves are at the same depth.
+// Returns the depth of the leaves below the node.
+func checkNode(t *testing.T, n *node, isRoot bool) int {
+	if n.currentSize > n.maxSize {
+		t.Error("node has too many items:", n.currentSize, n.items)
+	}
+	if !isRoot && n.currentSize < n.maxSize/2 {
+		t.Error("node has too few items:", n.currentSize, n.items)
+	}
+	for i := 1; i < n.currentSize; i++ {
+		if n.items[i-1].key > n.items[i].key {
+			t.Error("node items are out of order:", n.items)
+		}
+	}
+	if n.isLeaf {
+		return 1
+	}
+	depth := -1
+	for i := 0; i <= n.currentSize; i++ {
+		child := n.children[i]
+		if child.parent != n {
+			t.Error("child has the wrong parent:", child.items)
+		}
+		if i > 0 && child.currentSize > 0 && child.items[0].key < n.items[i-1].key {
+			t.Error("child has items before the separator:", child.items, n.items[i-1].key)
+		}
+		if i < n.currentSize && child.currentSize > 0 &&
+			child.items[child.currentSize-1].key > n.items[i].key {
+			t.Error("child has items after the separator:", child.items, n.items[i].key)
+		}
+		childDepth := checkNode(t, child, false)
+		if depth != -1 && childDepth != depth {
+			t.Error("leaves are at different depths:", depth, childDepth)
+		}
+		depth = childDepth
+	}
+	return depth + 1
+}
+
+// Test building trees directly from sorted items.
+func Test_BulkLoad(t *testing.T) {
+	for dimension := 1; dimension <= 4; dimension++ {
+		for count := 0; count < 200; count++ {
+			items := make([]item, count)
+			for i := 0; i < count; i++ {
+				items[i] = item{i, fmt.Sprintf("foo: %d", i)}
+			}
+			tree := newBTreeFromItems(dimension, items)
+			checkNode(t, tree.root, true)
+			keys := tree.root.keyTraversal()
+			if len(keys) != count {
+				t.Error("weird keys length:", dimension, count, keys)
+				continue
+			}
+			for i := 0; i < count; i++ {
+				if keys[i] != i {
+					t.Error("weird key values:", dimension, count, keys)
+					break
+				}
+			}
+			if count > 0 && tree.Search(count/2) != fmt.Sprintf("foo: %d", count/2) {
+				t.Error("Wrong value for key", count/2, tree.Search(count/2))
+			}
+		}
+	}
+
+	// The loaded tree should carry on working as usual.
+	items := make([]item, 50)
+	for i := 0; i < 50; i++ {
+		items[i] = item{2 * i, "even"}
+	}
+	tree := newBTreeFromItems(2, items)
+	for i := 0; i < 50; i++ {
+		tree.Insert(2*i+1, "odd")
+	}
+	checkNode(t, tree.root, true)
+	if tree.Size() != 100 {
+		t.Error("tree has wrong size:", tree.Size())
+	}
+	if tree.Search(37) != "odd" || tree.Search(38) != "even" {
+		t.Error("Wrong values found:", tree.Search(37), tree.Search(38))
+	}
+}
+
+// Test walking the items in a tree in sorted order.
+func Test_Iterator(t *testing.T) {
+	tree := NewBTree(2)
+	for i := 0; i < 100; i++ {
+		tree.Insert((i*37)%100, i)
+	}
+	it := newIterator(tree.root)
+	for i := 0; i < 100; i++ {
+		next, ok := it.next()
+		if !ok {
+			t.Error("iterator stopped early:", i)
+			break
+		}
+		if next.key != i {
+			t.Error("iterator returned the wrong key:", i, next.key)
+		}
+	}
+	if _, ok := it.next(); ok {
+		t.Error("iterator did not stop at the end")
+	}
+
+	if _, ok := newIterator(NewBTree(2).root).next(); ok {
+		t.Error("iterator found an item in an empty tree")
+	}
+}
+
+// Test inserting many items with the same keys. When a node splits, its
+// median has to go next to the node that split rather than wherever its
+// key first fits in the parent, since the parent may already have a
+// separator with the same key.
+func Test_InsertDuplicateKeys(t *testing.T) {
+	for dimension := 1; dimension <= 3; dimension++ {
+		tree := NewBTree(dimension)
+		counts := make(map[int]int)
+		random := rand.New(rand.NewSource(int64(dimension)))
+		for i := 0; i < 500; i++ {
+			key := random.Intn(5)
+			tree.Insert(key, i)
+			counts[key]++
+			checkNode(t, tree.root, true)
+		}
+		keys := tree.root.keyTraversal()
+		if len(keys) != 500 {
+			t.Error("weird keys length:", dimension, len(keys))
+		}
+		for i := 1; i < len(keys); i++ {
+			if keys[i-1] > keys[i] {
+				t.Error("keys are out of order:", dimension, keys)
+				break
+			}
+		}
+		for key, count := range counts {
+			if tree.Search(key) == nil {
+				t.Error("failed to find key", key, "inserted", count, "times")
+			}
+		}
+		if t.Failed() {
+			return
+		}
+	}
+}
+
+// Test adding a batch of items to a tree which already has items in it.
+func Test_InsertBatch(t *testing.T) {
+	tree := NewBTree(2)
+	expectedKeys := make([]int, 0)
+	for i := 0; i < 100; i += 3 {
+		tree.Insert(i, fmt.Sprintf("foo: %d", i))
+		expectedKeys = append(expectedKeys, i)
+	}
+	keys := make([]int, 0)
+	values := make([]interface{}, 0)
+	for i := 0; i < 300; i++ {
+		key := rand.Intn(200) - 50
+		keys = append(keys, key)
+		values = append(values, fmt.Sprintf("bar: %d", key))
+		expectedKeys = append(expectedKeys, key)
+	}
+	tree.InsertBatch(keys, values)
+	checkNode(t, tree.root, true)
+	if tree.Size() != len(expectedKeys) {
+		t.Error("tree has wrong size:", tree.Size(), len(expectedKeys))
+	}
+	sort.Ints(expectedKeys)
+	treeKeys := tree.root.keyTraversal()
+	for i := 0; i < len(treeKeys) && i < len(expectedKeys); i++ {
+		if treeKeys[i] != expectedKeys[i] {
+			t.Error("weird key values: ", treeKeys, expectedKeys)
+			break
+		}
+	}
+	if tree.Search(keys[17]) == nil {
+		t.Error("Could not find key from the batch:", keys[17])
+	}
+	if tree.Search(1000) != nil {
+		t.Error("Accidentally found a value for 1000.")
+	}
+}
+
+// Test adding a batch of items to an empty tree.
+func Test_InsertBatchEmptyTree(t *testing.T) {
+	tree := NewBTree(3)
+	keys := make([]int, 100)
+	values := make([]interface{}, 100)
+	for i := 0; i < 100; i++ {
+		keys[i] = 99 - i
+		values[i] = fmt.Sprintf("foo: %d", 99-i)
+	}
+	tree.InsertBatch(keys, values)
+	checkNode(t, tree.root, true)
+	if tree.Size() != 100 {
+		t.Error("tree has wrong size:", tree.Size())
+	}
+	if tree.Search(38) != "foo: 38" {
+		t.Error("Wrong value for key 38:", tree.Search(38))
+	}
+
+	// Items with the same key are kept in the order they were given.
+	tree = NewBTree(2)
+	tree.Insert(5, "first")
+	tree.InsertBatch([]int{5, 5}, []interface{}{"second", "third"})
+	if tree.root.items[1].value != "second" || tree.root.items[2].value != "third" {
+		t.Error("items with the same key are out of order:", tree.root.items)
+	}
+}
+
+// Build a batch of keys for the insertion benchmarks. Random batches use
+// the same keys every time, and sequential batches count up from start.
+func benchmarkBatch(size int, start int, random bool) ([]int, []interface{}) {
+	source := rand.New(rand.NewSource(int64(size)))
+	keys := make([]int, size)
+	values := make([]interface{}, size)
+	for i := 0; i < size; i++ {
+		if random {
+			keys[i] = source.Int()
+		} else {
+			keys[i] = start + i
+		}
+		values[i] = i
+	}
+	return keys, values
+}
+
+// Add a batch of keys to a tree which already holds some items, either by
+// calling Insert for each key or with a single call to InsertBatch.
+func benchmarkInsert(b *testing.B, random bool, batched bool) {
+	initialKeys, initialValues := benchmarkBatch(1000, 0, random)
+	keys, values := benchmarkBatch(10000, 1000, random)
+	for n := 0; n < b.N; n++ {
+		b.StopTimer()
+		tree := NewBTree(16)
+		tree.InsertBatch(initialKeys, initialValues)
+		b.StartTimer()
+		if batched {
+			tree.InsertBatch(keys, values)
+		} else {
+			for i := range keys {
+				tree.Insert(keys[i], values[i])
+			}
+		}
+	}
+}
+
+func BenchmarkInsertLoopRandom(b *testing.B) {
+	benchmarkInsert(b, true, false)
+}
+
+func BenchmarkInsertBatchRandom(b *testing.B) {
+	benchmarkInsert(b, true, true)
+}
+
+func BenchmarkInsertLoopSequential(b *testing.B) {
+	benchmarkInsert(b, false, false)
+}
+
+func BenchmarkInsertBatchSequential(b *testing.B) {
+	benchmarkInsert(b, false, true)
+}
+
+// Add a batch of keys to an empty tree, which is built directly.
+func BenchmarkInsertBatchEmptyTree(b *testing.B) {
+	keys, values := benchmarkBatch(10000, 0, true)
+	for n := 0; n < b.N; n++ {
+		NewBTree(16).InsertBatch(keys, values)
+	}
+}
+
+// Test removing items from a tree which is just a single leaf.
+func Test_RemoveFromLeaf(t *testing.T) {
+	tree := NewBTree(2)
+	tree.Insert(1, "foo")
+	tree.Insert(2, "bar")
+	tree.Insert(3, "baz")
+	if tree.Remove(2) != "bar" {
+		t.Error("Wrong value removed for key 2")
+	}
+	if tree.Search(2) != nil {
+		t.Error("Found key 2 after removing it:", tree.Search(2))
+	}
+	if tree.Size() != 2 || tree.root.items[1].key != 3 {
+		t.Error("node has the wrong items", tree.root.items)
+	}
+	if tree.Remove(2) != nil {
+		t.Error("Removed key 2 twice")
+	}
+	tree.Remove(1)
+	tree.Remove(3)
+	if tree.Size() != 0 {
+		t.Error("tree has wrong size:", tree.Size())
+	}
+}
+
+// Test removing every item from a larger tree in a random order, checking
+// the structure of the tree as we go.
+func Test_RemoveManyRandom(t *testing.T) {
+	for dimension := 1; dimension <= 3; dimension++ {
+		tree := NewBTree(dimension)
+		for i := 0; i < 200; i++ {
+			tree.Insert(i, fmt.Sprintf("foo: %d", i))
+		}
+		order := rand.Perm(200)
+		for n, key := range order {
+			if tree.Remove(key) != fmt.Sprintf("foo: %d", key) {
+				t.Error("Wrong value removed for key", key)
+			}
+			if tree.Search(key) != nil {
+				t.Error("Found key after removing it:", key)
+			}
+			if tree.Size() != 199-n {
+				t.Error("tree has wrong size:", tree.Size(), 199-n)
+				break
+			}
+			checkNode(t, tree.root, true)
+			if t.Failed() {
+				break
+			}
+		}
+		if !tree.root.isLeaf || tree.root.currentSize != 0 {
+			t.Error("tree is not empty:", tree.root)
+		}
+		// The tree should still be usable after removing everything.
+		tree.Insert(5, "again")
+		if tree.Search(5) != "again" {
+			t.Error("Could not find key after emptying the tree")
+		}
+	}
+}
+
+// Test removing items with the same key.
+func Test_RemoveDuplicates(t *testing.T) {
+	tree := NewBTree(2)
+	for i := 0; i < 20; i++ {
+		tree.Insert(i%4, i)
+	}
+	for i := 0; i < 5; i++ {
+		if tree.Remove(2) == nil {
+			t.Error("Did not find key 2 to remove:", i)
+		}
+		checkNode(t, tree.root, true)
+	}
+	if tree.Remove(2) != nil {
+		t.Error("Removed key 2 too many times")
+	}
+	if tree.Size() != 15 {
+		t.Error("tree has wrong size:", tree.Size())
+	}
+}
+
+// Test walking the whole tree in sorted order.
+func Test_Ascend(t *testing.T) {
+	tree := NewBTree(2)
+	for i := 0; i < 100; i++ {
+		tree.Insert((i*37)%100, i)
+	}
+	keys := make([]int, 0)
+	tree.Ascend(func(key int, value interface{}) bool {
+		keys = append(keys, key)
+		return len(keys) < 60
+	})
+	if len(keys) != 60 {
+		t.Error("did not stop early:", len(keys))
+	}
+	for i := range keys {
+		if keys[i] != i {
+			t.Error("weird key values:", keys)
+			break
+		}
+	}
+}
+
+// Test walking part of the tree in sorted order.
+func Test_AscendRange(t *testing.T) {
+	tree := NewBTree(2)
+	for i := 0; i < 100; i++ {
+		tree.Insert((i*37)%100*2, i)
+	}
+	for _, bounds := range [][2]int{{-10, 300}, {10, 20}, {11, 21}, {50, 51}, {51, 52}, {198, 500}, {300, 400}} {
+		keys := make([]int, 0)
+		tree.AscendRange(bounds[0], bounds[1], func(key int, value interface{}) bool {
+			keys = append(keys, key)
+			return true
+		})
+		expectedKeys := make([]int, 0)
+		for key := 0; key < 200; key += 2 {
+			if key >= bounds[0] && key < bounds[1] {
+				expectedKeys = append(expectedKeys, key)
+			}
+		}
+		if len(keys) != len(expectedKeys) {
+			t.Error("weird keys length:", bounds, keys, expectedKeys)
+			continue
+		}
+		for i := range keys {
+			if keys[i] != expectedKeys[i] {
+				t.Error("weird key values:", bounds, keys, expectedKeys)
+				break
+			}
+		}
+	}
+
+	// Stop once the function returns false.
+	count := 0
+	tree.AscendRange(0, 200, func(key int, value interface{}) bool {
+		count++
+		return count < 5
+	})
+	if count != 5 {
+		t.Error("did not stop early:", count)
+	}
+}
+
+// Search for random keys in a tree of a million items, at a range of
+// dimensions.
+func BenchmarkSearch(b *testing.B) {
+	keys, values := benchmarkBatch(1000000, 0, true)
+	for _, dimension := range []int{8, 16, 32, 64, 128} {
+		tree := NewBTree(dimension)
+		tree.InsertBatch(keys, values)
+		b.Run(fmt.Sprintf("dimension=%d", dimension), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				tree.Search(keys[n%len(keys)])
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"math/bits"
+	"time"
+)
+
+// Latencies below this many nanoseconds each get a bucket of their own.
+// Above it every power of two is split into half this many buckets, so a
+// bucket is never wider than about 1.6% of the latencies in it.
+const exactLatencies = 128
+
+// Enough buckets for any positive time.Duration.
+const numBuckets = exactLatencies + (63-7)*exactLatencies/2
+
+// Counts latencies in buckets which grow with the latency, so that a run of
+// any length takes the same small amount of memory. Percentiles are read
+// back as the top of their bucket, while the maximum is kept exactly.
+type latencyHistogram struct {
+	counts [numBuckets]int
+	count  int
+	max    time.Duration
+}
+
+// Count one latency.
+func (h *latencyHistogram) record(latency time.Duration) {
+	if latency < 0 {
+		latency = 0
+	}
+	h.counts[bucketOf(latency)]++
+	h.count++
+	if latency > h.max {
+		h.max = latency
+	}
+}
+
+// Find the latency which the given percentage of the recorded latencies
+// are at or below, to within the width of a bucket.
+func (h *latencyHistogram) percentile(percent float64) time.Duration {
+	rank := int(float64(h.count)*percent/100 + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	seen := 0
+	for bucket, count := range h.counts {
+		seen += count
+		if seen >= rank {
+			if top := bucketTop(bucket); top < h.max {
+				return top
+			}
+			break
+		}
+	}
+	return h.max
+}
+
+// The bucket a latency is counted in. Above exactLatencies the bucket is
+// found from the position of the highest set bit, which picks the power of
+// two, and the six bits below it, which pick the bucket within it.
+func bucketOf(latency time.Duration) int {
+	n := uint64(latency)
+	if n < exactLatencies {
+		return int(n)
+	}
+	shift := uint(bits.Len64(n) - 7)
+	return int(shift)*exactLatencies/2 + int(n>>shift)
+}
+
+// The largest latency which is counted in the bucket.
+func bucketTop(bucket int) time.Duration {
+	if bucket < exactLatencies {
+		return time.Duration(bucket)
+	}
+	shift := uint(bucket/(exactLatencies/2) - 1)
+	mantissa := uint64(bucket - int(shift)*exactLatencies/2)
+	return time.Duration((mantissa+1)<<shift - 1)
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/jackfhebert/btree"
+)
+
+// The kinds of operation a workload can mix together.
+const (
+	opRead = iota
+	opInsert
+	opRemove
+	opScan
+	numOps
+)
+
+var opNames = [numOps]string{"read", "insert", "remove", "scan"}
+
+// The percentage of each kind of operation in each workload, loosely
+// following the YCSB core workloads.
+var workloads = map[string][numOps]int{
+	"read-heavy":   {opRead: 95, opInsert: 5},
+	"write-heavy":  {opRead: 20, opInsert: 80},
+	"scan":         {opScan: 95, opInsert: 5},
+	"delete-churn": {opRead: 20, opInsert: 40, opRemove: 40},
+}
+
+// The settings for a single run.
+type config struct {
+	workload     string
+	distribution string
+	dimension    int
+	duration     time.Duration
+	// The number of keys to load before the run starts.
+	records int
+	// The number of items read by each scan.
+	scanLength int
+	seed       int64
+}
+
+// Picks the keys for reads, scans and removes out of the keys which have
+// been inserted so far, which are always [0, limit).
+type keyChooser interface {
+	next(limit int) int
+}
+
+// Every key is equally likely.
+type uniformChooser struct {
+	random *rand.Rand
+}
+
+func (c *uniformChooser) next(limit int) int {
+	return c.random.Intn(limit)
+}
+
+// A few keys are much more likely than the rest. The most popular keys
+// are the most recently inserted ones.
+type zipfianChooser struct {
+	random *rand.Rand
+	zipf   *rand.Zipf
+	// The number of keys the Zipf generator covers.
+	limit int
+}
+
+func (c *zipfianChooser) next(limit int) int {
+	// The Zipf generator has a fixed range and is slow to build, so rather
+	// than rebuilding it for every new key it covers only the newest keys
+	// until their number has doubled.
+	if c.zipf == nil || limit < c.limit || limit >= 2*c.limit {
+		c.zipf = rand.NewZipf(c.random, 1.1, 1, uint64(limit-1))
+		c.limit = limit
+	}
+	return limit - 1 - int(c.zipf.Uint64())
+}
+
+// Walks through the keys in order, starting again at the beginning once
+// it reaches the end.
+type sequentialChooser struct {
+	position int
+}
+
+func (c *sequentialChooser) next(limit int) int {
+	if c.position >= limit {
+		c.position = 0
+	}
+	c.position++
+	return c.position - 1
+}
+
+// Create the key chooser for a distribution name.
+func newKeyChooser(distribution string, random *rand.Rand) (keyChooser, error) {
+	switch distribution {
+	case "uniform":
+		return &uniformChooser{random}, nil
+	case "zipfian":
+		return &zipfianChooser{random: random}, nil
+	case "sequential":
+		return &sequentialChooser{}, nil
+	}
+	return nil, fmt.Errorf("unknown key distribution %q", distribution)
+}
+
+// The results of a run.
+type report struct {
+	Workload     string     `json:"workload"`
+	Distribution string     `json:"distribution"`
+	Dimension    int        `json:"dimension"`
+	Seconds      float64    `json:"seconds"`
+	Operations   int        `json:"operations"`
+	OpsPerSecond float64    `json:"ops_per_second"`
+	Size         int        `json:"final_size"`
+	Depth        int        `json:"final_depth"`
+	ByOperation  []opReport `json:"by_operation"`
+}
+
+// The results for one kind of operation, with latencies in nanoseconds.
+type opReport struct {
+	Name         string        `json:"name"`
+	Count        int           `json:"count"`
+	OpsPerSecond float64       `json:"ops_per_second"`
+	P50          time.Duration `json:"p50_ns"`
+	P90          time.Duration `json:"p90_ns"`
+	P99          time.Duration `json:"p99_ns"`
+	P999         time.Duration `json:"p999_ns"`
+	Max          time.Duration `json:"max_ns"`
+}
+
+// Load the tree and then run the workload against it until the duration is
+// up, timing every operation.
+func run(c config) (*report, error) {
+	mix, ok := workloads[c.workload]
+	if !ok {
+		return nil, fmt.Errorf("unknown workload %q", c.workload)
+	}
+	if c.records < 1 {
+		return nil, fmt.Errorf("need at least one record to load")
+	}
+	if c.dimension < 1 {
+		return nil, fmt.Errorf("need a dimension of at least 1")
+	}
+	random := rand.New(rand.NewSource(c.seed))
+	chooser, err := newKeyChooser(c.distribution, random)
+	if err != nil {
+		return nil, err
+	}
+
+	tree := BTree.NewBTree(c.dimension)
+	keys := make([]int, c.records)
+	values := make([]interface{}, c.records)
+	for i := range keys {
+		keys[i] = i
+		values[i] = i
+	}
+	tree.InsertBatch(keys, values)
+	// New keys are always added after the existing ones.
+	nextKey := c.records
+
+	var latencies [numOps]latencyHistogram
+	visit := func(key int, value interface{}) bool { return true }
+	start := time.Now()
+	deadline := start.Add(c.duration)
+	for n := 0; ; n++ {
+		// Checking the clock is relatively slow, so only do it every so often.
+		if n%1000 == 0 && time.Now().After(deadline) {
+			break
+		}
+		op := pickOp(mix, random)
+		// The key is chosen before the clock starts, so that only the
+		// operation itself is timed.
+		key := nextKey
+		if op != opInsert {
+			key = chooser.next(nextKey)
+		}
+		opStart := time.Now()
+		switch op {
+		case opRead:
+			tree.Search(key)
+		case opInsert:
+			tree.Insert(key, key)
+			nextKey++
+		case opRemove:
+			tree.Remove(key)
+		case opScan:
+			tree.AscendRange(key, key+c.scanLength, visit)
+		}
+		latencies[op].record(time.Since(opStart))
+	}
+	elapsed := time.Since(start).Seconds()
+
+	r := &report{
+		Workload:     c.workload,
+		Distribution: c.distribution,
+		Dimension:    c.dimension,
+		Seconds:      elapsed,
+		Size:         tree.Size(),
+		Depth:        tree.Depth(),
+	}
+	for op := range latencies {
+		if latencies[op].count == 0 {
+			continue
+		}
+		r.Operations += latencies[op].count
+		r.ByOperation = append(r.ByOperation, summarize(opNames[op], &latencies[op], elapsed))
+	}
+	r.OpsPerSecond = float64(r.Operations) / elapsed
+	return r, nil
+}
+
+// Choose an operation at random, weighted by the mix percentages.
+func pickOp(mix [numOps]int, random *rand.Rand) int {
+	choice := random.Intn(100)
+	for op, percent := range mix {
+		if choice < percent {
+			return op
+		}
+		choice -= percent
+	}
+	return opRead
+}
+
+// Work out the throughput and latency percentiles for one kind of operation.
+func summarize(name string, latencies *latencyHistogram, seconds float64) opReport {
+	return opReport{
+		Name:         name,
+		Count:        latencies.count,
+		OpsPerSecond: float64(latencies.count) / seconds,
+		P50:          latencies.percentile(50),
+		P90:          latencies.percentile(90),
+		P99:          latencies.percentile(99),
+		P999:         latencies.percentile(99.9),
+		Max:          latencies.max,
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test a short run of each workload and distribution.
+func Test_Run(t *testing.T) {
+	for workload := range workloads {
+		for _, distribution := range []string{"uniform", "zipfian", "sequential"} {
+			r, err := run(config{workload, distribution, 4, 20 * time.Millisecond, 1000, 10, 1})
+			if err != nil {
+				t.Error("run failed:", workload, distribution, err)
+				continue
+			}
+			if r.Operations == 0 || len(r.ByOperation) == 0 {
+				t.Error("no operations were run:", workload, distribution)
+			}
+			total := 0
+			for _, op := range r.ByOperation {
+				total += op.Count
+				if op.P50 > op.P99 || op.P99 > op.Max {
+					t.Error("latencies are out of order:", op)
+				}
+			}
+			if total != r.Operations {
+				t.Error("operation counts do not add up:", total, r.Operations)
+			}
+		}
+	}
+}
+
+// Test that bad settings are reported.
+func Test_RunErrors(t *testing.T) {
+	if _, err := run(config{"bogus", "uniform", 4, time.Millisecond, 10, 10, 1}); err == nil {
+		t.Error("unknown workload was accepted")
+	}
+	if _, err := run(config{"scan", "bogus", 4, time.Millisecond, 10, 10, 1}); err == nil {
+		t.Error("unknown distribution was accepted")
+	}
+	if _, err := run(config{"scan", "uniform", 4, time.Millisecond, 0, 10, 1}); err == nil {
+		t.Error("empty tree was accepted")
+	}
+	if _, err := run(config{"scan", "uniform", 0, time.Millisecond, 10, 10, 1}); err == nil {
+		t.Error("zero dimension was accepted")
+	}
+}
+
+// Test that the key choosers stay in range.
+func Test_KeyChoosers(t *testing.T) {
+	for _, distribution := range []string{"uniform", "zipfian", "sequential"} {
+		chooser, _ := newKeyChooser(distribution, rand.New(rand.NewSource(1)))
+		for limit := 1; limit < 200; limit++ {
+			key := chooser.next(limit)
+			if key < 0 || key >= limit {
+				t.Error("key out of range:", distribution, key, limit)
+			}
+		}
+	}
+	// The Zipf generator is only rebuilt once the number of keys doubles.
+	zipfian := &zipfianChooser{random: rand.New(rand.NewSource(1))}
+	zipfian.next(100)
+	first := zipfian.zipf
+	for limit := 101; limit < 200; limit++ {
+		if key := zipfian.next(limit); key < limit-100 || key >= limit {
+			t.Error("zipfian key out of range:", key, limit)
+		}
+	}
+	if zipfian.zipf != first {
+		t.Error("zipf generator rebuilt before the keys doubled")
+	}
+	if zipfian.next(200); zipfian.zipf == first {
+		t.Error("zipf generator not rebuilt once the keys doubled")
+	}
+
+	chooser := &sequentialChooser{}
+	for i := 0; i < 7; i++ {
+		if key := chooser.next(5); key != i%5 {
+			t.Error("sequential keys out of order:", i, key)
+		}
+	}
+}
+
+// Test picking percentiles out of the latency histogram.
+func Test_Percentile(t *testing.T) {
+	var latencies latencyHistogram
+	for i := 1; i <= 1000; i++ {
+		latencies.record(time.Duration(i))
+	}
+	// Each percentile is the top of its bucket, which is within 1/64 of
+	// the exact answer, apart from the maximum which is kept exactly.
+	for _, c := range []struct {
+		percent float64
+		exact   time.Duration
+	}{{50, 500}, {90, 900}, {99.9, 999}} {
+		got := latencies.percentile(c.percent)
+		if got < c.exact || got > c.exact+c.exact/64 {
+			t.Error("wrong percentile:", c.percent, got, c.exact)
+		}
+	}
+	if latencies.percentile(100) != 1000 || latencies.max != 1000 || latencies.count != 1000 {
+		t.Error("wrong maximum:", latencies.percentile(100), latencies.max, latencies.count)
+	}
+
+	var single latencyHistogram
+	single.record(time.Second)
+	if single.percentile(50) != time.Second {
+		t.Error("wrong percentile for a single latency:", single.percentile(50))
+	}
+}
+
+// Test that every latency lands in a bucket whose range holds it.
+func Test_Buckets(t *testing.T) {
+	latencies := []time.Duration{0, 1, 127, 128, 129, 255, 256, 1000, time.Millisecond,
+		time.Hour, 1<<62 + 12345, 1<<63 - 1}
+	for _, latency := range latencies {
+		bucket := bucketOf(latency)
+		if bucket < 0 || bucket >= numBuckets {
+			t.Error("bucket out of range:", latency, bucket)
+			continue
+		}
+		if bucketTop(bucket) < latency || (bucket > 0 && bucketTop(bucket-1) >= latency) {
+			t.Error("latency outside its bucket:", latency, bucket)
+		}
+	}
+	for bucket := 1; bucket < numBuckets; bucket++ {
+		if bucketOf(bucketTop(bucket)) != bucket {
+			t.Error("bucket tops out of order:", bucket)
+		}
+	}
+}
+
+// Test the text report.
+func Test_WriteText(t *testing.T) {
+	r, _ := run(config{"read-heavy", "uniform", 4, 10 * time.Millisecond, 100, 10, 1})
+	var buffer bytes.Buffer
+	if err := writeText(&buffer, r); err != nil {
+		t.Error("writeText failed:", err)
+	}
+	for _, expected := range []string{"workload read-heavy", "ops/sec", "read ", "insert "} {
+		if !strings.Contains(buffer.String(), expected) {
+			t.Error("missing from report:", expected, buffer.String())
+		}
+	}
+}
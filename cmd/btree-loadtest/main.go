@@ -0,0 +1,66 @@
+/*
+A simple load test for the BTree, which runs a mix of operations against a
+single tree for a while and reports the throughput and latencies.
+
+	btree-loadtest -workload read-heavy -distribution zipfian -duration 10s
+
+The workloads are read-heavy, write-heavy, scan and delete-churn, and the
+key distributions are uniform, zipfian and sequential.
+*/
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+func main() {
+	var c config
+	flag.StringVar(&c.workload, "workload", "read-heavy",
+		"the mix of operations: read-heavy, write-heavy, scan or delete-churn")
+	flag.StringVar(&c.distribution, "distribution", "uniform",
+		"how keys are chosen: uniform, zipfian or sequential")
+	flag.IntVar(&c.dimension, "dimension", 16, "the dimension of the tree")
+	flag.DurationVar(&c.duration, "duration", 10*time.Second, "how long to run for")
+	flag.IntVar(&c.records, "records", 100000, "the number of keys to load before starting")
+	flag.IntVar(&c.scanLength, "scan-length", 100, "the range of keys covered by each scan")
+	flag.Int64Var(&c.seed, "seed", 1, "the seed for the random number generator")
+	asJSON := flag.Bool("json", false, "print the report as JSON")
+	flag.Parse()
+
+	r, err := run(c)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "btree-loadtest:", err)
+		os.Exit(2)
+	}
+	if *asJSON {
+		err = json.NewEncoder(os.Stdout).Encode(r)
+	} else {
+		err = writeText(os.Stdout, r)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "btree-loadtest:", err)
+		os.Exit(1)
+	}
+}
+
+// Print the report as a table.
+func writeText(w io.Writer, r *report) error {
+	fmt.Fprintf(w, "workload %s, %s keys, dimension %d\n", r.Workload, r.Distribution, r.Dimension)
+	fmt.Fprintf(w, "%d operations in %.1fs: %.0f ops/sec\n", r.Operations, r.Seconds, r.OpsPerSecond)
+	fmt.Fprintf(w, "final size %d, depth %d\n\n", r.Size, r.Depth)
+	fmt.Fprintf(w, "%-8s %10s %12s %10s %10s %10s %10s %10s\n",
+		"op", "count", "ops/sec", "p50", "p90", "p99", "p99.9", "max")
+	for _, op := range r.ByOperation {
+		_, err := fmt.Fprintf(w, "%-8s %10d %12.0f %10v %10v %10v %10v %10v\n",
+			op.Name, op.Count, op.OpsPerSecond, op.P50, op.P90, op.P99, op.P999, op.Max)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
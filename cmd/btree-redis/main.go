@@ -0,0 +1,37 @@
+/*
+A server speaking the Redis protocol (RESP2) in front of BTrees, so that
+redis-cli and existing Redis clients can be used against them.
+
+	btree-redis -addr localhost:6379 -dimension 16
+
+It understands PING, QUIT, GET, SET, DEL and SCAN for string values under
+integer keys, and ZADD, ZRANGE, ZRANGEBYSCORE and ZRANK for sorted sets
+with integer scores, each of which is held in its own tree. Options beyond
+SCAN's MATCH and COUNT and ZRANGEBYSCORE's WITHSCORES and LIMIT are not
+supported. Everything lives in memory and is lost when the server stops.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:6379", "the address to listen on")
+	dimension := flag.Int("dimension", 16, "the dimension of the trees")
+	flag.Parse()
+	if *dimension < 1 {
+		fmt.Fprintln(os.Stderr, "btree-redis: -dimension must be at least 1")
+		os.Exit(2)
+	}
+
+	listener, err := net.Listen("tcp", *addr)
+	if err == nil {
+		err = newServer(*dimension).serve(listener)
+	}
+	fmt.Fprintln(os.Stderr, "btree-redis:", err)
+	os.Exit(1)
+}
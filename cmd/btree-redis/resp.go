@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Limits on the commands accepted from clients, so that a bad length can't
+// make the server allocate huge amounts of memory. Memory for arguments is
+// only taken as they arrive, starting from room for preallocatedArgs.
+const (
+	maxArgs          = 1024 * 1024
+	maxBulkBytes     = 64 * 1024 * 1024
+	maxLineBytes     = 64 * 1024
+	preallocatedArgs = 1024
+)
+
+// Returned for input which does not follow the protocol, after which the
+// connection is closed.
+var errProtocol = errors.New("Protocol error")
+
+// Read a command, either as an array of bulk strings (which is how clients
+// send them) or as a line of words separated by spaces (which is what gets
+// typed into telnet). Returns an empty command for a blank line.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return strings.Fields(line), nil
+	}
+	count, err := strconv.Atoi(line[1:])
+	if err != nil || count < 0 || count > maxArgs {
+		return nil, errProtocol
+	}
+	capacity := count
+	if capacity > preallocatedArgs {
+		capacity = preallocatedArgs
+	}
+	args := make([]string, 0, capacity)
+	for i := 0; i < count; i++ {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line, "$") {
+			return nil, errProtocol
+		}
+		length, err := strconv.Atoi(line[1:])
+		if err != nil || length < 0 || length > maxBulkBytes {
+			return nil, errProtocol
+		}
+		// The string is followed by its own line ending. The buffer grows as
+		// the data is read, rather than trusting the length up front.
+		var data bytes.Buffer
+		if _, err := io.CopyN(&data, r, int64(length)+2); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		if !bytes.HasSuffix(data.Bytes(), []byte("\r\n")) {
+			return nil, errProtocol
+		}
+		args = append(args, string(data.Bytes()[:length]))
+	}
+	return args, nil
+}
+
+// Read a line without its line ending, which is meant to be \r\n but may
+// just be \n from people typing. Lines longer than maxLineBytes are a
+// protocol error.
+func readLine(r *bufio.Reader) (string, error) {
+	line := make([]byte, 0)
+	for {
+		chunk, err := r.ReadSlice('\n')
+		line = append(line, chunk...)
+		if len(line) > maxLineBytes {
+			return "", errProtocol
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			if err == io.EOF && len(line) > 0 {
+				return "", io.ErrUnexpectedEOF
+			}
+			return "", err
+		}
+		break
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(string(line), "\n"), "\r"), nil
+}
+
+// Writes replies to a client. Errors are left for the final Flush.
+type respWriter struct {
+	*bufio.Writer
+}
+
+func (w respWriter) simple(s string) {
+	w.WriteString("+" + s + "\r\n")
+}
+
+func (w respWriter) error(s string) {
+	w.WriteString("-" + s + "\r\n")
+}
+
+func (w respWriter) integer(i int) {
+	w.WriteString(":" + strconv.Itoa(i) + "\r\n")
+}
+
+func (w respWriter) bulk(s string) {
+	w.WriteString("$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n")
+}
+
+// Write the nil bulk string, for a value which is not found.
+func (w respWriter) null() {
+	w.WriteString("$-1\r\n")
+}
+
+// Write the start of an array, which must be followed by its n elements.
+func (w respWriter) array(n int) {
+	w.WriteString("*" + strconv.Itoa(n) + "\r\n")
+}
+
+// Write an array of bulk strings.
+func (w respWriter) bulks(strs []string) {
+	w.array(len(strs))
+	for _, s := range strs {
+		w.bulk(s)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func Test_ReadCommand(t *testing.T) {
+	input := "*3\r\n$3\r\nSET\r\n$1\r\n5\r\n$12\r\nhello\r\nthere\r\n" +
+		"GET 5\n" +
+		"\r\n" +
+		"*1\r\n$0\r\n\r\n"
+	r := bufio.NewReader(strings.NewReader(input))
+	expected := [][]string{{"SET", "5", "hello\r\nthere"}, {"GET", "5"}, {}, {""}}
+	for _, want := range expected {
+		args, err := readCommand(r)
+		if err != nil {
+			t.Fatal("readCommand failed:", err)
+		}
+		if len(args) != len(want) || (len(want) > 0 && !reflect.DeepEqual(args, want)) {
+			t.Error("wrong command:", args, want)
+		}
+	}
+	if _, err := readCommand(r); err != io.EOF {
+		t.Error("wrong error at the end of the input:", err)
+	}
+}
+
+func Test_ReadCommandErrors(t *testing.T) {
+	for _, input := range []string{
+		"*x\r\n",
+		"*-1\r\n",
+		"*1\r\n+GET\r\n",
+		"*1\r\n$-1\r\n",
+		"*1\r\n$3\r\nGETX\r\n",
+		"*1\r\n$999999999999\r\n",
+		strings.Repeat("a", maxLineBytes+1) + "\r\n",
+		"*1\r\n$" + strings.Repeat("1", maxLineBytes),
+	} {
+		if _, err := readCommand(bufio.NewReader(strings.NewReader(input))); err != errProtocol {
+			t.Errorf("wrong error for %q: %v", input, err)
+		}
+	}
+	// Running out of input part way through a command is not a clean end.
+	if _, err := readCommand(bufio.NewReader(strings.NewReader("*2\r\n$3\r\nGET\r\n"))); err != io.EOF {
+		t.Error("wrong error for a truncated command:", err)
+	}
+	if _, err := readCommand(bufio.NewReader(strings.NewReader("GET"))); err != io.ErrUnexpectedEOF {
+		t.Error("wrong error for a truncated line:", err)
+	}
+	if _, err := readCommand(bufio.NewReader(strings.NewReader("*1\r\n$5\r\nGE"))); err != io.ErrUnexpectedEOF {
+		t.Error("wrong error for a truncated string:", err)
+	}
+}
+
+// Test that lengths claimed by a command don't get memory allocated for
+// them before the data arrives.
+func Test_ReadCommandAllocations(t *testing.T) {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	readCommand(bufio.NewReader(strings.NewReader(fmt.Sprintf("*%d\r\n", maxArgs))))
+	readCommand(bufio.NewReader(strings.NewReader(fmt.Sprintf("*1\r\n$%d\r\nGET", maxBulkBytes))))
+	runtime.ReadMemStats(&after)
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1024*1024 {
+		t.Error("allocated too much for lengths without data:", allocated)
+	}
+}
+
+func Test_Writer(t *testing.T) {
+	var out bytes.Buffer
+	w := respWriter{bufio.NewWriter(&out)}
+	w.simple("OK")
+	w.error("ERR bad")
+	w.integer(-3)
+	w.bulk("a\r\nb")
+	w.null()
+	w.bulks([]string{"x", ""})
+	w.Flush()
+	expected := "+OK\r\n-ERR bad\r\n:-3\r\n$4\r\na\r\nb\r\n$-1\r\n*2\r\n$1\r\nx\r\n$0\r\n\r\n"
+	if out.String() != expected {
+		t.Errorf("wrong output: %q", out.String())
+	}
+}
@@ -0,0 +1,414 @@
+package main
+
+import (
+	"bufio"
+	"math"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jackfhebert/btree"
+)
+
+// The number of keys SCAN looks at when it is not given a COUNT.
+const defaultScanCount = 10
+
+// Serves a tree of string values under integer keys, along with sorted
+// sets of members with integer scores, to clients speaking RESP2.
+type server struct {
+	// Guards everything below, since the trees are not safe to use from
+	// several goroutines.
+	lock      sync.Mutex
+	dimension int
+	keys      *BTree.BTree
+	// The sorted sets by name. These are kept apart from the keys, so a
+	// sorted set can have the same name as a key.
+	sets map[string]*sortedSet
+}
+
+func newServer(dimension int) *server {
+	return &server{dimension: dimension, keys: BTree.NewBTree(dimension), sets: make(map[string]*sortedSet)}
+}
+
+// Accept connections from the listener until it is closed, serving each
+// one in its own goroutine.
+func (s *server) serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handle(conn)
+	}
+}
+
+// Run the commands sent over a connection until it is closed.
+func (s *server) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	w := respWriter{bufio.NewWriter(conn)}
+	for {
+		args, err := readCommand(r)
+		if err == errProtocol {
+			w.error("ERR " + err.Error())
+			w.Flush()
+			return
+		}
+		if err != nil {
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		if strings.ToUpper(args[0]) == "QUIT" {
+			w.simple("OK")
+			w.Flush()
+			return
+		}
+		s.lock.Lock()
+		s.execute(w, args)
+		s.lock.Unlock()
+		// Commands can be pipelined, so only send the replies once all of
+		// the commands which have arrived so far have been run.
+		if r.Buffered() == 0 {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Run a single command and write its reply.
+func (s *server) execute(w respWriter, args []string) {
+	name := strings.ToUpper(args[0])
+	args = args[1:]
+	switch {
+	case name == "PING" && len(args) <= 1:
+		if len(args) == 1 {
+			w.bulk(args[0])
+		} else {
+			w.simple("PONG")
+		}
+	case name == "GET" && len(args) == 1:
+		s.get(w, args[0])
+	case name == "SET" && len(args) == 2:
+		s.set(w, args[0], args[1])
+	case name == "DEL" && len(args) >= 1:
+		s.del(w, args)
+	case name == "SCAN" && len(args) >= 1:
+		s.scan(w, args[0], args[1:])
+	case name == "ZADD" && len(args) >= 3 && len(args)%2 == 1:
+		s.zadd(w, args[0], args[1:])
+	case name == "ZRANGE" && (len(args) == 3 || len(args) == 4):
+		s.zrange(w, args[0], args[1], args[2], args[3:])
+	case name == "ZRANGEBYSCORE" && len(args) >= 3:
+		s.zrangeByScore(w, args[0], args[1], args[2], args[3:])
+	case name == "ZRANK" && len(args) == 2:
+		s.zrank(w, args[0], args[1])
+	default:
+		switch name {
+		case "PING", "GET", "SET", "DEL", "SCAN", "ZADD", "ZRANGE", "ZRANGEBYSCORE", "ZRANK":
+			w.error("ERR wrong number of arguments for '" + strings.ToLower(name) + "' command")
+		default:
+			w.error("ERR unknown command '" + shorten(name) + "'")
+		}
+	}
+}
+
+// Keep unknown command names short in error messages.
+func shorten(name string) string {
+	if len(name) > 64 {
+		return name[:64] + "..."
+	}
+	return name
+}
+
+func (s *server) get(w respWriter, k string) {
+	key, ok := parseKey(w, k)
+	if !ok {
+		return
+	}
+	if value := s.keys.Search(key); value != nil {
+		w.bulk(value.(string))
+	} else {
+		w.null()
+	}
+}
+
+// Set the value of a key, replacing any value it already has.
+func (s *server) set(w respWriter, k, value string) {
+	key, ok := parseKey(w, k)
+	if !ok {
+		return
+	}
+	s.keys.Remove(key)
+	s.keys.Insert(key, value)
+	w.simple("OK")
+}
+
+// Remove keys, replying with how many of them were found.
+func (s *server) del(w respWriter, ks []string) {
+	keys := make([]int, len(ks))
+	for i, k := range ks {
+		key, ok := parseKey(w, k)
+		if !ok {
+			return
+		}
+		keys[i] = key
+	}
+	removed := 0
+	for _, key := range keys {
+		if s.keys.Remove(key) != nil {
+			removed++
+		}
+	}
+	w.integer(removed)
+}
+
+// SCAN cursor [MATCH pattern] [COUNT count]
+//
+// Cursors are unsigned integers, as clients expect, with 0 meaning the
+// start (or, in a reply, that there is nothing left). Any other cursor is
+// one more than the first key of the next batch with its sign bit flipped,
+// so that keys added or removed between calls don't cause other keys to be
+// skipped or repeated.
+func (s *server) scan(w respWriter, c string, options []string) {
+	cursor, err := strconv.ParseUint(c, 10, 64)
+	if err != nil {
+		w.error("ERR invalid cursor")
+		return
+	}
+	start := math.MinInt
+	if cursor != 0 {
+		start = int((cursor - 1) ^ (1 << 63))
+	}
+	pattern, count := "", defaultScanCount
+	for i := 0; i < len(options); i += 2 {
+		if i+1 == len(options) {
+			w.error("ERR syntax error")
+			return
+		}
+		switch strings.ToUpper(options[i]) {
+		case "MATCH":
+			pattern = options[i+1]
+			if _, err := path.Match(pattern, ""); err != nil {
+				w.error("ERR bad MATCH pattern")
+				return
+			}
+		case "COUNT":
+			if count, err = strconv.Atoi(options[i+1]); err != nil || count < 1 {
+				w.error("ERR value is not an integer or out of range")
+				return
+			}
+		default:
+			w.error("ERR syntax error")
+			return
+		}
+	}
+
+	keys := make([]string, 0)
+	next := uint64(0)
+	seen := 0
+	visit := func(key int, value interface{}) bool {
+		// There is no cursor for the largest key, so it always goes in
+		// with the batch before it.
+		if seen == count && key != math.MaxInt {
+			next = (uint64(key) ^ (1 << 63)) + 1
+			return false
+		}
+		seen++
+		k := strconv.Itoa(key)
+		// Keys contain no slashes, so path.Match works like Redis globs.
+		if matched, _ := path.Match(pattern, k); pattern == "" || matched {
+			keys = append(keys, k)
+		}
+		return true
+	}
+	s.keys.AscendRange(start, math.MaxInt, visit)
+	if next == 0 {
+		if value := s.keys.Search(math.MaxInt); value != nil {
+			visit(math.MaxInt, value)
+		}
+	}
+	w.array(2)
+	w.bulk(strconv.FormatUint(next, 10))
+	w.bulks(keys)
+}
+
+// ZADD name score member [score member ...], replying with the number of
+// members which were added rather than having their scores changed.
+func (s *server) zadd(w respWriter, name string, pairs []string) {
+	scores := make([]int, len(pairs)/2)
+	for i := range scores {
+		score, err := strconv.Atoi(pairs[2*i])
+		if err != nil {
+			w.error("ERR value is not an integer or out of range")
+			return
+		}
+		scores[i] = score
+	}
+	set := s.sets[name]
+	if set == nil {
+		set = newSortedSet(s.dimension)
+		s.sets[name] = set
+	}
+	added := 0
+	for i, score := range scores {
+		if set.add(pairs[2*i+1], score) {
+			added++
+		}
+	}
+	w.integer(added)
+}
+
+// ZRANGE name start stop [WITHSCORES], where negative positions count back
+// from the end of the set.
+func (s *server) zrange(w respWriter, name, startArg, stopArg string, options []string) {
+	withScores, ok := parseWithScores(w, options)
+	if !ok {
+		return
+	}
+	start, err1 := strconv.Atoi(startArg)
+	stop, err2 := strconv.Atoi(stopArg)
+	if err1 != nil || err2 != nil {
+		w.error("ERR value is not an integer or out of range")
+		return
+	}
+	set := s.sets[name]
+	if set == nil {
+		w.array(0)
+		return
+	}
+	if start < 0 {
+		start += set.size()
+	}
+	if stop < 0 {
+		stop += set.size()
+	}
+	if start < 0 {
+		start = 0
+	}
+	writeMembers(w, set.byRank(start, stop), withScores)
+}
+
+// ZRANGEBYSCORE name min max [WITHSCORES] [LIMIT offset count], where the
+// bounds may be -inf or +inf, or start with ( to leave the bound out.
+func (s *server) zrangeByScore(w respWriter, name, minArg, maxArg string, options []string) {
+	min, minEmpty, minOk := parseBound(minArg, true)
+	max, maxEmpty, maxOk := parseBound(maxArg, false)
+	if !minOk || !maxOk {
+		w.error("ERR min or max is not an integer")
+		return
+	}
+	withScores := false
+	offset, count := 0, -1
+	for i := 0; i < len(options); i++ {
+		switch strings.ToUpper(options[i]) {
+		case "WITHSCORES":
+			withScores = true
+		case "LIMIT":
+			if i+2 >= len(options) {
+				w.error("ERR syntax error")
+				return
+			}
+			var err1, err2 error
+			offset, err1 = strconv.Atoi(options[i+1])
+			count, err2 = strconv.Atoi(options[i+2])
+			if err1 != nil || err2 != nil {
+				w.error("ERR value is not an integer or out of range")
+				return
+			}
+			i += 2
+		default:
+			w.error("ERR syntax error")
+			return
+		}
+	}
+	set := s.sets[name]
+	if set == nil || minEmpty || maxEmpty || offset < 0 {
+		w.array(0)
+		return
+	}
+	writeMembers(w, set.byScore(min, max, offset, count), withScores)
+}
+
+func (s *server) zrank(w respWriter, name, member string) {
+	set := s.sets[name]
+	if set == nil {
+		w.null()
+		return
+	}
+	if rank, found := set.rank(member); found {
+		w.integer(rank)
+	} else {
+		w.null()
+	}
+}
+
+// Parse a key, replying with an error if it is not an integer.
+func parseKey(w respWriter, k string) (int, bool) {
+	key, err := strconv.Atoi(k)
+	if err != nil {
+		w.error("ERR keys must be integers")
+		return 0, false
+	}
+	return key, true
+}
+
+func parseWithScores(w respWriter, options []string) (bool, bool) {
+	if len(options) == 0 {
+		return false, true
+	}
+	if strings.ToUpper(options[0]) != "WITHSCORES" {
+		w.error("ERR syntax error")
+		return false, false
+	}
+	return true, true
+}
+
+// Parse a score bound, turning an exclusive bound into the inclusive bound
+// next to it. Returns true for empty if an exclusive bound leaves nothing
+// in range, and false for ok if the bound can't be parsed.
+func parseBound(arg string, isMin bool) (bound int, empty bool, ok bool) {
+	switch strings.ToLower(arg) {
+	case "-inf":
+		return math.MinInt, false, true
+	case "+inf", "inf":
+		return math.MaxInt, false, true
+	}
+	exclusive := strings.HasPrefix(arg, "(")
+	bound, err := strconv.Atoi(strings.TrimPrefix(arg, "("))
+	if err != nil {
+		return 0, false, false
+	}
+	if exclusive && isMin {
+		if bound == math.MaxInt {
+			return 0, true, true
+		}
+		bound++
+	} else if exclusive {
+		if bound == math.MinInt {
+			return 0, true, true
+		}
+		bound--
+	}
+	return bound, false, true
+}
+
+// Write the members as an array, following each one with its score if
+// withScores is set.
+func writeMembers(w respWriter, members []scoredMember, withScores bool) {
+	if withScores {
+		w.array(2 * len(members))
+	} else {
+		w.array(len(members))
+	}
+	for _, m := range members {
+		w.bulk(m.member)
+		if withScores {
+			w.bulk(strconv.Itoa(m.score))
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// A client for tests, which sends commands and reads back the replies.
+type client struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// Start a server on a local port and connect a client to it.
+func startServer(t *testing.T) *client {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go newServer(2).serve(listener)
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &client{conn, bufio.NewReader(conn)}
+}
+
+// Send a command as an array of bulk strings and return the reply.
+func (c *client) do(t *testing.T, args ...string) interface{} {
+	command := "*" + strconv.Itoa(len(args)) + "\r\n"
+	for _, arg := range args {
+		command += "$" + strconv.Itoa(len(arg)) + "\r\n" + arg + "\r\n"
+	}
+	if _, err := io.WriteString(c.conn, command); err != nil {
+		t.Fatal(err)
+	}
+	reply, err := readReply(c.r)
+	if err != nil {
+		t.Fatal("failed to read a reply:", err)
+	}
+	return reply
+}
+
+// An error reply.
+type replyError string
+
+// Read a reply as a string (for simple and bulk strings), replyError, int,
+// nil or []interface{}.
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if line == "" {
+		return nil, fmt.Errorf("empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return replyError(line[1:]), nil
+	case ':':
+		return strconv.Atoi(line[1:])
+	case '$':
+		length, err := strconv.Atoi(line[1:])
+		if err != nil || length < 0 {
+			return nil, err
+		}
+		data := make([]byte, length+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return string(data[:length]), nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		elements := make([]interface{}, count)
+		for i := range elements {
+			if elements[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return elements, nil
+	}
+	return nil, fmt.Errorf("bad reply %q", line)
+}
+
+// Build the reply expected for an array of strings.
+func array(strs ...string) []interface{} {
+	elements := make([]interface{}, len(strs))
+	for i, s := range strs {
+		elements[i] = s
+	}
+	return elements
+}
+
+func check(t *testing.T, c *client, expected interface{}, args ...string) {
+	t.Helper()
+	if reply := c.do(t, args...); !reflect.DeepEqual(reply, expected) {
+		t.Errorf("%v: got %#v, expected %#v", args, reply, expected)
+	}
+}
+
+func Test_Keys(t *testing.T) {
+	c := startServer(t)
+	check(t, c, "PONG", "PING")
+	check(t, c, "OK", "SET", "5", "five")
+	check(t, c, "five", "GET", "5")
+	check(t, c, "OK", "set", "5", "FIVE")
+	check(t, c, "FIVE", "get", "5")
+	check(t, c, nil, "GET", "6")
+	check(t, c, "OK", "SET", "-1", "")
+	check(t, c, 2, "DEL", "5", "6", "-1")
+	check(t, c, nil, "GET", "5")
+
+	check(t, c, replyError("ERR keys must be integers"), "GET", "five")
+	check(t, c, replyError("ERR wrong number of arguments for 'get' command"), "GET")
+	check(t, c, replyError("ERR unknown command 'FLUSHALL'"), "FLUSHALL")
+}
+
+// Scan through all of the keys a batch at a time, following the cursors.
+func Test_Scan(t *testing.T) {
+	c := startServer(t)
+	for i := -20; i < 20; i++ {
+		c.do(t, "SET", strconv.Itoa(i*3), "x")
+	}
+	c.do(t, "SET", "9223372036854775807", "max")
+
+	keys := make([]string, 0)
+	cursor := "0"
+	for batches := 0; ; batches++ {
+		if batches > 20 {
+			t.Fatal("too many batches")
+		}
+		reply := c.do(t, "SCAN", cursor, "COUNT", "7").([]interface{})
+		for _, key := range reply[1].([]interface{}) {
+			keys = append(keys, key.(string))
+		}
+		if cursor = reply[0].(string); cursor == "0" {
+			break
+		}
+	}
+	if len(keys) != 41 || keys[0] != "-60" || keys[39] != "57" || keys[40] != "9223372036854775807" {
+		t.Error("wrong keys from scan:", keys)
+	}
+
+	reply := c.do(t, "SCAN", "0", "MATCH", "1*", "COUNT", "1000").([]interface{})
+	if !reflect.DeepEqual(reply, []interface{}{"0", array("12", "15", "18")}) {
+		t.Error("wrong keys from scan with match:", reply)
+	}
+	check(t, c, replyError("ERR invalid cursor"), "SCAN", "-1")
+	check(t, c, replyError("ERR syntax error"), "SCAN", "0", "COUNT")
+}
+
+func Test_SortedSets(t *testing.T) {
+	c := startServer(t)
+	check(t, c, 3, "ZADD", "board", "10", "ann", "20", "bob", "10", "al")
+	check(t, c, 1, "ZADD", "board", "30", "cat", "5", "bob")
+	check(t, c, array("bob", "al", "ann", "cat"), "ZRANGE", "board", "0", "-1")
+	check(t, c, array("ann", "10", "cat", "30"), "ZRANGE", "board", "-2", "10", "WITHSCORES")
+	check(t, c, array(), "ZRANGE", "board", "3", "1")
+	check(t, c, array("al", "ann"), "ZRANGEBYSCORE", "board", "10", "(30")
+	check(t, c, array("ann", "cat"), "ZRANGEBYSCORE", "board", "(5", "+inf", "LIMIT", "1", "5")
+	check(t, c, array("bob", "5"), "ZRANGEBYSCORE", "board", "-inf", "5", "WITHSCORES")
+	check(t, c, array(), "ZRANGEBYSCORE", "board", "(9223372036854775807", "+inf")
+	check(t, c, 2, "ZRANK", "board", "ann")
+	check(t, c, nil, "ZRANK", "board", "dan")
+	check(t, c, nil, "ZRANK", "nothing", "dan")
+	check(t, c, array(), "ZRANGE", "nothing", "0", "-1")
+
+	// Sorted sets don't get in the way of keys with the same name.
+	check(t, c, "OK", "SET", "7", "seven")
+	check(t, c, 1, "ZADD", "7", "1", "one")
+	check(t, c, "seven", "GET", "7")
+
+	check(t, c, replyError("ERR value is not an integer or out of range"), "ZADD", "board", "1.5", "x")
+	check(t, c, replyError("ERR wrong number of arguments for 'zadd' command"), "ZADD", "board", "1")
+	check(t, c, replyError("ERR min or max is not an integer"), "ZRANGEBYSCORE", "board", "a", "1")
+}
+
+// Commands sent together, and typed in as plain lines, all get replies.
+func Test_PipelineAndInline(t *testing.T) {
+	c := startServer(t)
+	io.WriteString(c.conn, "SET 1 one\r\nSET 2 two\nGET 2\r\n*1\r\n$4\r\nQUIT\r\n")
+	for _, expected := range []interface{}{"OK", "OK", "two", "OK"} {
+		reply, err := readReply(c.r)
+		if err != nil || reply != expected {
+			t.Error("wrong reply:", reply, err, expected)
+		}
+	}
+	// QUIT closes the connection.
+	if _, err := readReply(c.r); err != io.EOF {
+		t.Error("connection still open after QUIT:", err)
+	}
+}
+
+func Test_ProtocolError(t *testing.T) {
+	c := startServer(t)
+	io.WriteString(c.conn, "*1\r\n+PING\r\n")
+	if reply, _ := readReply(c.r); reply != replyError("ERR Protocol error") {
+		t.Error("wrong reply to a protocol error:", reply)
+	}
+	if _, err := readReply(c.r); err != io.EOF {
+		t.Error("connection still open after a protocol error:", err)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/jackfhebert/btree"
+)
+
+// A sorted set of members with integer scores, ordered by score and then
+// by member.
+type sortedSet struct {
+	scores map[string]int
+	// Keyed by score, with each value being the sorted []string of the
+	// members with that score.
+	tree *BTree.BTree
+}
+
+// A member along with its score.
+type scoredMember struct {
+	member string
+	score  int
+}
+
+func newSortedSet(dimension int) *sortedSet {
+	return &sortedSet{make(map[string]int), BTree.NewBTree(dimension)}
+}
+
+// Set the score of a member, adding it if it is new. Returns true if it
+// was added.
+func (set *sortedSet) add(member string, score int) bool {
+	old, found := set.scores[member]
+	if found {
+		if old == score {
+			return false
+		}
+		set.removeFromScore(member, old)
+	}
+	set.scores[member] = score
+	members, _ := set.tree.Remove(score).([]string)
+	i := sort.SearchStrings(members, member)
+	members = append(members, "")
+	copy(members[i+1:], members[i:])
+	members[i] = member
+	set.tree.Insert(score, members)
+	return !found
+}
+
+func (set *sortedSet) removeFromScore(member string, score int) {
+	members := set.tree.Remove(score).([]string)
+	i := sort.SearchStrings(members, member)
+	members = append(members[:i], members[i+1:]...)
+	if len(members) > 0 {
+		set.tree.Insert(score, members)
+	}
+}
+
+func (set *sortedSet) size() int {
+	return len(set.scores)
+}
+
+// Find the position of a member in the set, counting from 0, or false if
+// it is not in the set. This has to count every member before it.
+func (set *sortedSet) rank(member string) (int, bool) {
+	score, found := set.scores[member]
+	if !found {
+		return 0, false
+	}
+	rank := 0
+	set.tree.Ascend(func(key int, value interface{}) bool {
+		members := value.([]string)
+		if key == score {
+			rank += sort.SearchStrings(members, member)
+			return false
+		}
+		rank += len(members)
+		return true
+	})
+	return rank, true
+}
+
+// List the members with positions from start to stop, inclusive.
+func (set *sortedSet) byRank(start, stop int) []scoredMember {
+	results := make([]scoredMember, 0)
+	rank := 0
+	set.tree.Ascend(func(score int, value interface{}) bool {
+		for _, member := range value.([]string) {
+			if rank > stop {
+				return false
+			}
+			if rank >= start {
+				results = append(results, scoredMember{member, score})
+			}
+			rank++
+		}
+		return true
+	})
+	return results
+}
+
+// List the members with scores from min to max, inclusive, skipping the
+// first offset of them and returning at most count (or all if count is
+// negative).
+func (set *sortedSet) byScore(min, max, offset, count int) []scoredMember {
+	results := make([]scoredMember, 0)
+	visit := func(score int, value interface{}) bool {
+		for _, member := range value.([]string) {
+			if count >= 0 && len(results) == count {
+				return false
+			}
+			if offset > 0 {
+				offset--
+				continue
+			}
+			results = append(results, scoredMember{member, score})
+		}
+		return true
+	}
+	// The range given to AscendRange leaves out its end, so the max score
+	// is visited separately.
+	more := true
+	if min < max {
+		set.tree.AscendRange(min, max, func(score int, value interface{}) bool {
+			more = visit(score, value)
+			return more
+		})
+	}
+	if more && min <= max {
+		if members := set.tree.Search(max); members != nil {
+			visit(max, members)
+		}
+	}
+	return results
+}
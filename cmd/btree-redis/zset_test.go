@@ -0,0 +1,51 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func members(results []scoredMember) []string {
+	names := make([]string, len(results))
+	for i, result := range results {
+		names[i] = result.member
+	}
+	return names
+}
+
+// Members are ordered by score and then by name, whatever order they are
+// added in.
+func Test_SortedSetOrder(t *testing.T) {
+	set := newSortedSet(2)
+	for i, member := range []string{"e", "c", "a", "d", "b", "f", "g"} {
+		set.add(member, i%3)
+	}
+	// Scores are e0 c1 a2 d0 b1 f2 g0.
+	expected := []string{"d", "e", "g", "b", "c", "a", "f"}
+	if got := members(set.byRank(0, 100)); !reflect.DeepEqual(got, expected) {
+		t.Error("wrong order:", got)
+	}
+	for i, member := range expected {
+		if rank, found := set.rank(member); !found || rank != i {
+			t.Error("wrong rank for", member, rank, found)
+		}
+	}
+	if got := members(set.byScore(1, 2, 1, 2)); !reflect.DeepEqual(got, []string{"c", "a"}) {
+		t.Error("wrong members by score:", got)
+	}
+
+	// Moving a member takes it out of its old score.
+	if set.add("e", 5) || set.add("e", 5) {
+		t.Error("added a member which was already in the set")
+	}
+	if got := members(set.byScore(math.MinInt, 0, 0, -1)); !reflect.DeepEqual(got, []string{"d", "g"}) {
+		t.Error("wrong members after moving one:", got)
+	}
+	if rank, _ := set.rank("e"); rank != 6 || set.size() != 7 {
+		t.Error("wrong rank after moving a member:", rank, set.size())
+	}
+	if _, found := set.rank("z"); found {
+		t.Error("found a rank for a missing member")
+	}
+}
@@ -0,0 +1,42 @@
+/*
+An HTTP server holding a BTree of JSON values, for use as a local index.
+
+	btree-server -addr localhost:8080 -dimension 16
+
+The endpoints are:
+
+	GET    /keys/{k}   the value under the integer key k
+	PUT    /keys/{k}   store the JSON request body under k, replacing any value
+	DELETE /keys/{k}   remove the value under k
+	GET    /range?start=&end=&limit=&cursor=
+	                   items with keys in [start, end), a page at a time
+	GET    /stats      the size and shape of the tree
+
+A range response holds up to limit items (100 by default, and at most 1000)
+along with a next cursor when there are more. Passing that cursor back, with
+the same end and limit, gets the following page. The tree only lives in
+memory, so everything is lost when the server stops.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:8080", "the address to listen on")
+	dimension := flag.Int("dimension", 16, "the dimension of the tree")
+	flag.Parse()
+	if *dimension < 1 {
+		fmt.Fprintln(os.Stderr, "btree-server: -dimension must be at least 1")
+		os.Exit(2)
+	}
+
+	if err := http.ListenAndServe(*addr, newServer(*dimension).handler()); err != nil {
+		fmt.Fprintln(os.Stderr, "btree-server:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jackfhebert/btree"
+)
+
+// The number of items returned by a range request without a limit, and
+// the most that a single request can ask for.
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// Serves a tree of JSON values over HTTP. Each key holds at most one
+// value, since PUT replaces whatever was there.
+type server struct {
+	// Guards the tree, which is not safe to use from several goroutines.
+	lock sync.RWMutex
+	tree *BTree.BTree
+}
+
+// An item in the response to a range request.
+type rangeItem struct {
+	Key   int             `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+// The response to a range request. Next is the cursor to pass to get the
+// following page, and is left out on the last page.
+type rangeResponse struct {
+	Items []rangeItem `json:"items"`
+	Next  string      `json:"next,omitempty"`
+}
+
+type statsResponse struct {
+	Size int `json:"size"`
+	BTree.Stats
+}
+
+func newServer(dimension int) *server {
+	return &server{tree: BTree.NewBTree(dimension)}
+}
+
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/keys/", s.handleKey)
+	mux.HandleFunc("/range", s.handleRange)
+	mux.HandleFunc("/stats", s.handleStats)
+	return mux
+}
+
+// GET, PUT or DELETE the value under /keys/{k}.
+func (s *server) handleKey(w http.ResponseWriter, r *http.Request) {
+	key, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/keys/"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "keys must be integers")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		s.lock.RLock()
+		value := s.tree.Search(key)
+		s.lock.RUnlock()
+		if value == nil {
+			writeError(w, http.StatusNotFound, "key not found")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(value.(json.RawMessage))
+	case http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if !json.Valid(body) {
+			writeError(w, http.StatusBadRequest, "the value must be JSON")
+			return
+		}
+		s.lock.Lock()
+		replaced := s.tree.Remove(key) != nil
+		s.tree.Insert(key, json.RawMessage(body))
+		s.lock.Unlock()
+		if replaced {
+			w.WriteHeader(http.StatusNoContent)
+		} else {
+			w.WriteHeader(http.StatusCreated)
+		}
+	case http.MethodDelete:
+		s.lock.Lock()
+		value := s.tree.Remove(key)
+		s.lock.Unlock()
+		if value == nil {
+			writeError(w, http.StatusNotFound, "key not found")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// GET up to limit items with keys in [start, end), or continuing from a
+// cursor returned by an earlier request. Without start or end the range is
+// open at that end.
+func (s *server) handleRange(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	query := r.URL.Query()
+	start, err := intParam(query.Get("start"), math.MinInt)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad start: "+err.Error())
+		return
+	}
+	// The cursor is the key of the first item on the next page.
+	if cursor := query.Get("cursor"); cursor != "" {
+		if start, err = strconv.Atoi(cursor); err != nil {
+			writeError(w, http.StatusBadRequest, "bad cursor")
+			return
+		}
+	}
+	openEnd := query.Get("end") == ""
+	end, err := intParam(query.Get("end"), math.MaxInt)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad end: "+err.Error())
+		return
+	}
+	limit, err := intParam(query.Get("limit"), defaultLimit)
+	if err != nil || limit < 1 || limit > maxLimit {
+		writeError(w, http.StatusBadRequest, "limit must be from 1 to "+strconv.Itoa(maxLimit))
+		return
+	}
+
+	response := rangeResponse{Items: make([]rangeItem, 0)}
+	// Fetch one more item than asked for, to find where the next page
+	// starts.
+	var next *rangeItem
+	collect := func(key int, value interface{}) bool {
+		if len(response.Items) == limit {
+			next = &rangeItem{key, nil}
+			return false
+		}
+		response.Items = append(response.Items, rangeItem{key, value.(json.RawMessage)})
+		return true
+	}
+	s.lock.RLock()
+	s.tree.AscendRange(start, end, collect)
+	// AscendRange never includes its end, so an open range has to check
+	// the largest possible key separately.
+	if openEnd && next == nil {
+		if value := s.tree.Search(math.MaxInt); value != nil {
+			collect(math.MaxInt, value)
+		}
+	}
+	s.lock.RUnlock()
+	if next != nil {
+		response.Next = strconv.Itoa(next.Key)
+	}
+	writeJSON(w, response)
+}
+
+// GET the size and shape of the tree.
+func (s *server) handleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	s.lock.RLock()
+	response := statsResponse{s.tree.Size(), s.tree.Stats()}
+	s.lock.RUnlock()
+	writeJSON(w, response)
+}
+
+// Parse an integer query parameter, which is given the default value if
+// it is missing.
+func intParam(param string, defaultValue int) (int, error) {
+	if param == "" {
+		return defaultValue, nil
+	}
+	value, err := strconv.Atoi(param)
+	if err != nil {
+		return 0, errors.New("not an integer")
+	}
+	return value, nil
+}
+
+func writeJSON(w http.ResponseWriter, response interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
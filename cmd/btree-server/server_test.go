@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Make a request to the server and return the status and body.
+func do(t *testing.T, server *httptest.Server, method, path, body string) (int, string) {
+	request, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := server.Client().Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response.StatusCode, strings.TrimSpace(string(data))
+}
+
+func Test_Keys(t *testing.T) {
+	server := httptest.NewServer(newServer(2).handler())
+	defer server.Close()
+
+	if status, _ := do(t, server, "PUT", "/keys/5", `{"name":"five"}`); status != http.StatusCreated {
+		t.Error("wrong status for a new key:", status)
+	}
+	if status, body := do(t, server, "GET", "/keys/5", ""); status != http.StatusOK || body != `{"name":"five"}` {
+		t.Error("wrong value:", status, body)
+	}
+	// PUT replaces rather than adding a duplicate.
+	if status, _ := do(t, server, "PUT", "/keys/5", `"FIVE"`); status != http.StatusNoContent {
+		t.Error("wrong status for replacing a key:", status)
+	}
+	if status, body := do(t, server, "GET", "/keys/5", ""); status != http.StatusOK || body != `"FIVE"` {
+		t.Error("wrong value after replacing:", status, body)
+	}
+	if status, _ := do(t, server, "DELETE", "/keys/5", ""); status != http.StatusNoContent {
+		t.Error("wrong status for delete:", status)
+	}
+	if status, _ := do(t, server, "GET", "/keys/5", ""); status != http.StatusNotFound {
+		t.Error("found a deleted key:", status)
+	}
+	if status, _ := do(t, server, "DELETE", "/keys/5", ""); status != http.StatusNotFound {
+		t.Error("deleted a missing key:", status)
+	}
+
+	for _, bad := range []struct{ method, path, body string }{
+		{"GET", "/keys/five", ""},
+		{"PUT", "/keys/1", "not json"},
+	} {
+		if status, _ := do(t, server, bad.method, bad.path, bad.body); status != http.StatusBadRequest {
+			t.Error("accepted a bad request:", bad, status)
+		}
+	}
+	if status, _ := do(t, server, "POST", "/keys/1", "1"); status != http.StatusMethodNotAllowed {
+		t.Error("accepted a POST:", status)
+	}
+}
+
+// Page through a range and check that each key is seen exactly once.
+func Test_RangePages(t *testing.T) {
+	server := httptest.NewServer(newServer(2).handler())
+	defer server.Close()
+	for i := 0; i < 50; i++ {
+		do(t, server, "PUT", "/keys/"+strconv.Itoa(i*2), strconv.Itoa(i))
+	}
+
+	keys := make([]int, 0)
+	path := "/range?start=11&end=60&limit=7"
+	for pages := 0; ; pages++ {
+		if pages > 10 {
+			t.Fatal("too many pages")
+		}
+		status, body := do(t, server, "GET", path, "")
+		if status != http.StatusOK {
+			t.Fatal("range failed:", status, body)
+		}
+		var response rangeResponse
+		if err := json.Unmarshal([]byte(body), &response); err != nil {
+			t.Fatal(err)
+		}
+		if len(response.Items) > 7 {
+			t.Error("page is too long:", len(response.Items))
+		}
+		for _, item := range response.Items {
+			keys = append(keys, item.Key)
+			if string(item.Value) != strconv.Itoa(item.Key/2) {
+				t.Error("wrong value:", item.Key, string(item.Value))
+			}
+		}
+		if response.Next == "" {
+			break
+		}
+		path = "/range?end=60&limit=7&cursor=" + response.Next
+	}
+	if len(keys) != 24 || keys[0] != 12 || keys[len(keys)-1] != 58 {
+		t.Error("wrong keys from range:", keys)
+	}
+	for i := 1; i < len(keys); i++ {
+		if keys[i] != keys[i-1]+2 {
+			t.Error("keys missing or repeated:", keys)
+			break
+		}
+	}
+
+	for _, bad := range []string{"/range?limit=0", "/range?limit=5000", "/range?start=x", "/range?cursor=x"} {
+		if status, _ := do(t, server, "GET", bad, ""); status != http.StatusBadRequest {
+			t.Error("accepted a bad range:", bad, status)
+		}
+	}
+}
+
+// An open ended range includes the largest possible key, which the
+// half-open range [start, end) can't otherwise reach.
+func Test_RangeOpenEnd(t *testing.T) {
+	server := httptest.NewServer(newServer(2).handler())
+	defer server.Close()
+	do(t, server, "PUT", "/keys/-9223372036854775808", `"min"`)
+	do(t, server, "PUT", "/keys/9223372036854775807", `"max"`)
+	_, body := do(t, server, "GET", "/range", "")
+	expected := `{"items":[{"key":-9223372036854775808,"value":"min"},{"key":9223372036854775807,"value":"max"}]}`
+	if body != expected {
+		t.Error("wrong open range:", body)
+	}
+}
+
+func Test_Stats(t *testing.T) {
+	server := httptest.NewServer(newServer(2).handler())
+	defer server.Close()
+	for i := 0; i < 20; i++ {
+		do(t, server, "PUT", "/keys/"+strconv.Itoa(i), "null")
+	}
+	status, body := do(t, server, "GET", "/stats", "")
+	var stats statsResponse
+	if err := json.Unmarshal([]byte(body), &stats); status != http.StatusOK || err != nil {
+		t.Fatal("stats failed:", status, body, err)
+	}
+	if stats.Size != 20 || stats.Height < 2 {
+		t.Error("wrong stats:", body)
+	}
+}
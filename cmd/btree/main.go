@@ -0,0 +1,46 @@
+/*
+An interactive shell for exploring a BTree and reproducing problems with it.
+
+	btree -dimension 2 -file items.txt
+
+Type help at the prompt for the list of commands. Trees are saved to and
+loaded from text files with one item per line, written as the key and the
+value separated by a tab. Without -file the tree starts off empty and only
+lives in memory.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/jackfhebert/btree"
+)
+
+func main() {
+	dimension := flag.Int("dimension", 2, "the dimension of the tree")
+	file := flag.String("file", "", "a file to load the tree from, which save writes back to")
+	quiet := flag.Bool("quiet", false, "don't print a prompt, for reading commands from a script")
+	flag.Parse()
+	if *dimension < 1 {
+		fmt.Fprintln(os.Stderr, "btree: -dimension must be at least 1")
+		os.Exit(2)
+	}
+
+	s := &session{BTree.NewBTree(*dimension), *dimension, *file, os.Stdout}
+	if *file != "" {
+		tree, err := loadFile(*file, *dimension)
+		if err != nil && !os.IsNotExist(err) {
+			fmt.Fprintln(os.Stderr, "btree:", err)
+			os.Exit(1)
+		}
+		if err == nil {
+			s.tree = tree
+		}
+	}
+	if err := s.run(os.Stdin, !*quiet); err != nil {
+		fmt.Fprintln(os.Stderr, "btree:", err)
+		os.Exit(1)
+	}
+}
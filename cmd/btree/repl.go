@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jackfhebert/btree"
+)
+
+const helpText = `commands:
+  put k v      add the value v (the rest of the line) under the key k
+  get k        print the value found for the key k
+  del k        remove the key k and print its value
+  range a b    print the items with keys from a up to (but not including) b
+  dump         print the tree one node per line, indented by depth
+  stats        print the shape of the tree
+  load file    replace the tree with the items in the file
+  save [file]  write the items to the file, or back to the -file file
+  help         print this message
+  quit         exit
+`
+
+// The state of an interactive session.
+type session struct {
+	tree      *BTree.BTree
+	dimension int
+	// The file given on the command line, which save writes to by default.
+	file string
+	out  io.Writer
+}
+
+// Run the commands read from in until quit or the end of the input.
+// Errors from individual commands are printed and don't stop the session.
+func (s *session) run(in io.Reader, prompt bool) error {
+	scanner := bufio.NewScanner(in)
+	for {
+		if prompt {
+			fmt.Fprint(s.out, "btree> ")
+		}
+		if !scanner.Scan() {
+			return scanner.Err()
+		}
+		quit, err := s.execute(scanner.Text())
+		if err != nil {
+			fmt.Fprintln(s.out, "error:", err)
+		}
+		if quit {
+			return nil
+		}
+	}
+}
+
+// Run a single command. Returns true if the session should end.
+func (s *session) execute(line string) (bool, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return false, nil
+	}
+	command, args := fields[0], fields[1:]
+	switch command {
+	case "put":
+		if len(args) < 2 {
+			return false, errors.New("usage: put k v")
+		}
+		key, err := strconv.Atoi(args[0])
+		if err != nil {
+			return false, err
+		}
+		// The value is everything after the key, spaces and all.
+		value := strings.TrimSpace(line)
+		value = strings.TrimSpace(strings.TrimPrefix(value, command))
+		value = strings.TrimSpace(strings.TrimPrefix(value, args[0]))
+		s.tree.Insert(key, value)
+	case "get", "del":
+		if len(args) != 1 {
+			return false, fmt.Errorf("usage: %s k", command)
+		}
+		key, err := strconv.Atoi(args[0])
+		if err != nil {
+			return false, err
+		}
+		var value interface{}
+		if command == "get" {
+			value = s.tree.Search(key)
+		} else {
+			value = s.tree.Remove(key)
+		}
+		if value == nil {
+			fmt.Fprintln(s.out, "(not found)")
+		} else {
+			fmt.Fprintln(s.out, value)
+		}
+	case "range":
+		if len(args) != 2 {
+			return false, errors.New("usage: range a b")
+		}
+		start, err := strconv.Atoi(args[0])
+		if err != nil {
+			return false, err
+		}
+		end, err := strconv.Atoi(args[1])
+		if err != nil {
+			return false, err
+		}
+		s.tree.AscendRange(start, end, func(key int, value interface{}) bool {
+			fmt.Fprintf(s.out, "%d\t%v\n", key, value)
+			return true
+		})
+	case "dump":
+		fmt.Fprintf(s.out, "%+v\n", s.tree)
+	case "stats":
+		stats := s.tree.Stats()
+		fmt.Fprintf(s.out, "items %d, height %d, nodes %d (%d leaves), nodes per level %v\n",
+			stats.UsedSlots, stats.Height, stats.Nodes, stats.Leaves, stats.NodesPerLevel)
+		fmt.Fprintf(s.out, "fill average %.2f, min %.2f, slots %d/%d, about %d bytes\n",
+			stats.AverageFill, stats.MinFill, stats.UsedSlots, stats.AllocatedSlots,
+			stats.EstimatedBytes)
+	case "load":
+		if len(args) != 1 {
+			return false, errors.New("usage: load file")
+		}
+		tree, err := loadFile(args[0], s.dimension)
+		if err != nil {
+			return false, err
+		}
+		s.tree = tree
+	case "save":
+		file := s.file
+		if len(args) == 1 {
+			file = args[0]
+		}
+		if file == "" || len(args) > 1 {
+			return false, errors.New("usage: save file")
+		}
+		return false, saveFile(file, s.tree)
+	case "help":
+		fmt.Fprint(s.out, helpText)
+	case "quit", "exit":
+		return true, nil
+	default:
+		return false, fmt.Errorf("unknown command %q, try help", command)
+	}
+	return false, nil
+}
+
+// Read a tree from a file with one item per line, written as the key and
+// the value separated by a tab.
+func loadFile(name string, dimension int) (*BTree.BTree, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	keys := make([]int, 0)
+	values := make([]interface{}, 0)
+	scanner := bufio.NewScanner(f)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		fields := strings.SplitN(scanner.Text(), "\t", 2)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected a key and value separated by a tab", name, lineNumber)
+		}
+		key, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", name, lineNumber, err)
+		}
+		keys = append(keys, key)
+		values = append(values, fields[1])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	tree := BTree.NewBTree(dimension)
+	tree.InsertBatch(keys, values)
+	return tree, nil
+}
+
+// Write the items in the tree to a file in the format read by loadFile.
+func saveFile(name string, tree *BTree.BTree) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	tree.Ascend(func(key int, value interface{}) bool {
+		fmt.Fprintf(w, "%d\t%v\n", key, value)
+		return true
+	})
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
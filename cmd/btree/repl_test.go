@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jackfhebert/btree"
+)
+
+// Run a script of commands in a new session and return the output.
+func runScript(t *testing.T, s *session, script string) string {
+	var out bytes.Buffer
+	s.out = &out
+	if err := s.run(strings.NewReader(script), false); err != nil {
+		t.Error("run failed:", err)
+	}
+	return out.String()
+}
+
+func newSession() *session {
+	return &session{BTree.NewBTree(2), 2, "", nil}
+}
+
+// Test the basic commands.
+func Test_Commands(t *testing.T) {
+	output := runScript(t, newSession(), `put 3 three
+put 1 one and a bit
+put 2 two
+get 1
+get 5
+del 3
+del 3
+range 0 10
+quit
+get 2
+`)
+	expected := "one and a bit\n(not found)\nthree\n(not found)\n1\tone and a bit\n2\ttwo\n"
+	if output != expected {
+		t.Error("wrong output:", output)
+	}
+}
+
+// Test that bad commands are reported without ending the session.
+func Test_CommandErrors(t *testing.T) {
+	output := runScript(t, newSession(), "bogus\nput x y\nget\nrange 1\nload\nsave\nput 1 one\nget 1\n")
+	if strings.Count(output, "error:") != 6 {
+		t.Error("wrong number of errors:", output)
+	}
+	if !strings.HasSuffix(output, "one\n") {
+		t.Error("session stopped after an error:", output)
+	}
+}
+
+// Test printing the tree and its stats.
+func Test_DumpAndStats(t *testing.T) {
+	output := runScript(t, newSession(), "put 1 a\nput 2 b\nput 3 c\nput 4 d\nput 5 e\ndump\nstats\n")
+	if !strings.Contains(output, "[3] 1/4\n  [1 2] 2/4\n  [4 5] 2/4\n") {
+		t.Error("wrong dump:", output)
+	}
+	if !strings.Contains(output, "items 5, height 2, nodes 3 (2 leaves)") {
+		t.Error("wrong stats:", output)
+	}
+}
+
+// Test saving a tree to a file and loading it back.
+func Test_SaveAndLoad(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "items.txt")
+
+	s := newSession()
+	s.file = file
+	runScript(t, s, "put 2 two\nput 1 one\tand a tab\nput 1 uno\nsave\n")
+	contents, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) != "1\tone\tand a tab\n1\tuno\n2\ttwo\n" {
+		t.Error("wrong file contents:", string(contents))
+	}
+
+	output := runScript(t, newSession(), "load "+file+"\nrange 0 5\n")
+	if output != string(contents) {
+		t.Error("wrong items after loading:", output)
+	}
+
+	os.WriteFile(file, []byte("1\tone\nnot a key\tvalue\n"), 0644)
+	output = runScript(t, newSession(), "load "+file+"\n")
+	if !strings.Contains(output, "items.txt:2:") {
+		t.Error("bad file was not reported:", output)
+	}
+}
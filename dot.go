@@ -0,0 +1,114 @@
+package BTree
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// Extra details to include when writing a tree in the DOT language.
+type DOTOptions struct {
+	// Draw a dashed edge from each node back up to its parent pointer.
+	ParentEdges bool
+	// Label the leaf nodes.
+	LeafFlags bool
+	// Label each node with how many items it holds out of its maximum.
+	Occupancy bool
+}
+
+// Write the structure of the tree in the Graphviz DOT language, which can
+// be rendered with something like `dot -Tsvg`. Each node is drawn as a
+// record holding its keys, with an edge to each of its children.
+func (tree *BTree) WriteDOT(w io.Writer) error {
+	return tree.WriteAnnotatedDOT(w, DOTOptions{})
+}
+
+// Write the structure of the tree in the DOT language as WriteDOT does,
+// along with the extra details turned on in the options.
+func (tree *BTree) WriteAnnotatedDOT(w io.Writer, options DOTOptions) error {
+	d := &dotWriter{options: options, names: make(map[*node]string)}
+	d.buffer.WriteString("digraph BTree {\n")
+	d.buffer.WriteString("\tnode [shape=record];\n")
+	d.writeNode(tree.root)
+	if options.ParentEdges {
+		d.writeParentEdges()
+	}
+	d.buffer.WriteString("}\n")
+	_, err := w.Write(d.buffer.Bytes())
+	return err
+}
+
+// The state kept while writing a tree in the DOT language.
+type dotWriter struct {
+	buffer  bytes.Buffer
+	options DOTOptions
+	// The name of each node written so far, and the nodes in the order
+	// they were written.
+	names map[*node]string
+	nodes []*node
+}
+
+// Write this node and everything below it. Nodes are named in the order
+// they are written, and the names are kept so that parent pointers can be
+// drawn. Returns the name of this node.
+func (d *dotWriter) writeNode(node *node) string {
+	name := fmt.Sprintf("node%d", len(d.nodes))
+	d.names[node] = name
+	d.nodes = append(d.nodes, node)
+
+	// Each key sits between fields for the child pointers on either side
+	// of it, which the edges to the children start from.
+	label := "<c0>"
+	for i := 0; i < node.currentSize; i++ {
+		label += fmt.Sprintf("|%d|<c%d>", node.items[i].key, i+1)
+	}
+	annotation := ""
+	if d.options.LeafFlags && node.isLeaf {
+		annotation = "leaf"
+	}
+	if d.options.Occupancy {
+		if annotation != "" {
+			annotation += " "
+		}
+		annotation += fmt.Sprintf("%d/%d", node.currentSize, node.maxSize)
+	}
+	if annotation != "" {
+		fmt.Fprintf(&d.buffer, "\t%s [label=\"%s\", xlabel=\"%s\"];\n", name, label, annotation)
+	} else {
+		fmt.Fprintf(&d.buffer, "\t%s [label=\"%s\"];\n", name, label)
+	}
+
+	if !node.isLeaf {
+		for i := 0; i <= node.currentSize; i++ {
+			childName := d.writeNode(node.children[i])
+			fmt.Fprintf(&d.buffer, "\t%s:c%d -> %s;\n", name, i, childName)
+		}
+	}
+	return name
+}
+
+// Draw a dashed edge from each node back up to its parent pointer. These
+// come after all of the nodes so that a pointer to any node in the tree
+// can be drawn. A pointer to a node which is not in the tree is a bug, so
+// it gets a placeholder node of its own, which keeps the graph valid and
+// shows where the pointer went wrong.
+func (d *dotWriter) writeParentEdges() {
+	unknown := make(map[*node]string)
+	for _, node := range d.nodes {
+		if node.parent == nil {
+			continue
+		}
+		parent, ok := d.names[node.parent]
+		if !ok {
+			parent, ok = unknown[node.parent]
+		}
+		if !ok {
+			parent = fmt.Sprintf("unknown%d", len(unknown))
+			unknown[node.parent] = parent
+			fmt.Fprintf(&d.buffer, "\t%s [shape=box, color=red, label=\"not in tree: %p\"];\n",
+				parent, node.parent)
+		}
+		fmt.Fprintf(&d.buffer, "\t%s -> %s [style=dashed, color=gray, constraint=false];\n",
+			d.names[node], parent)
+	}
+}
@@ -0,0 +1,95 @@
+package BTree
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// Test writing a small tree with a single split.
+func Test_WriteDOT(t *testing.T) {
+	tree := buildTree("tree", []int{1, 2, 3, 4, 5})
+	var buffer bytes.Buffer
+	if err := tree.WriteDOT(&buffer); err != nil {
+		t.Error("WriteDOT failed:", err)
+	}
+	expected := `digraph BTree {
+	node [shape=record];
+	node0 [label="<c0>|3|<c1>"];
+	node1 [label="<c0>|1|<c1>|2|<c2>"];
+	node0:c0 -> node1;
+	node2 [label="<c0>|4|<c1>|5|<c2>"];
+	node0:c1 -> node2;
+}
+`
+	if buffer.String() != expected {
+		t.Error("wrong DOT output:", buffer.String())
+	}
+}
+
+// Test writing the optional annotations.
+func Test_WriteAnnotatedDOT(t *testing.T) {
+	tree := buildTree("tree", []int{1, 2, 3, 4, 5})
+	var buffer bytes.Buffer
+	options := DOTOptions{ParentEdges: true, LeafFlags: true, Occupancy: true}
+	if err := tree.WriteAnnotatedDOT(&buffer, options); err != nil {
+		t.Error("WriteAnnotatedDOT failed:", err)
+	}
+	output := buffer.String()
+	expectedLines := []string{
+		`node0 [label="<c0>|3|<c1>", xlabel="1/4"];`,
+		`node1 [label="<c0>|1|<c1>|2|<c2>", xlabel="leaf 2/4"];`,
+		`node1 -> node0 [style=dashed, color=gray, constraint=false];`,
+		`node2 -> node0 [style=dashed, color=gray, constraint=false];`,
+	}
+	for _, line := range expectedLines {
+		if !strings.Contains(output, line) {
+			t.Error("missing line:", line, output)
+		}
+	}
+
+	// An empty tree is still a valid graph.
+	buffer.Reset()
+	NewBTree(2).WriteAnnotatedDOT(&buffer, DOTOptions{LeafFlags: true})
+	if !strings.Contains(buffer.String(), `node0 [label="<c0>", xlabel="leaf"];`) {
+		t.Error("wrong DOT output for an empty tree:", buffer.String())
+	}
+}
+
+// Test that a tree with broken parent pointers still makes a valid graph,
+// with the stray parents drawn as placeholders.
+func Test_WriteDOTBrokenParents(t *testing.T) {
+	tree := buildTree("tree", []int{1, 2, 3, 4, 5})
+	stray := NewBTree(2).root
+	tree.root.children[0].parent = stray
+	tree.root.children[1].parent = stray
+	var buffer bytes.Buffer
+	tree.WriteAnnotatedDOT(&buffer, DOTOptions{ParentEdges: true})
+	output := buffer.String()
+	if strings.Contains(output, "-> ;") || strings.Count(output, "unknown0 [shape=box") != 1 {
+		t.Error("wrong placeholder for a stray parent:", output)
+	}
+	for _, line := range []string{
+		`node1 -> unknown0 [style=dashed, color=gray, constraint=false];`,
+		`node2 -> unknown0 [style=dashed, color=gray, constraint=false];`,
+	} {
+		if !strings.Contains(output, line) {
+			t.Error("missing line:", line, output)
+		}
+	}
+}
+
+// A writer which always fails.
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("failed")
+}
+
+// Test that write errors are returned.
+func Test_WriteDOTError(t *testing.T) {
+	if NewBTree(2).WriteDOT(failingWriter{}) == nil {
+		t.Error("WriteDOT did not return the write error")
+	}
+}
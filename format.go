@@ -0,0 +1,75 @@
+package BTree
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Describe the tree level by level, one line per level starting at the
+// root, with the keys of each node in brackets. For example:
+//
+//	[3]
+//	[1 2] [4 5]
+func (tree *BTree) String() string {
+	var buffer bytes.Buffer
+	level := []*node{tree.root}
+	for len(level) > 0 {
+		next := make([]*node, 0)
+		for i, n := range level {
+			if i > 0 {
+				buffer.WriteString(" ")
+			}
+			buffer.WriteString(n.keyString())
+			if !n.isLeaf {
+				next = append(next, n.children[:n.currentSize+1]...)
+			}
+		}
+		if len(next) > 0 {
+			buffer.WriteString("\n")
+		}
+		level = next
+	}
+	return buffer.String()
+}
+
+// Print the tree for the fmt package. %v and %s print the same layout as
+// String, while %+v prints the tree indented by depth with one node per
+// line and how many items each node holds out of its maximum:
+//
+//	[3] 1/4
+//	  [1 2] 2/4
+//	  [4 5] 2/4
+func (tree *BTree) Format(f fmt.State, verb rune) {
+	switch {
+	case verb == 'v' && f.Flag('+'):
+		var buffer bytes.Buffer
+		tree.root.writeIndented(&buffer, 0)
+		// Drop the newline after the last node, as String does.
+		f.Write(bytes.TrimSuffix(buffer.Bytes(), []byte("\n")))
+	case verb == 'v' || verb == 's':
+		f.Write([]byte(tree.String()))
+	default:
+		fmt.Fprintf(f, "%%!%c(*BTree=%s)", verb, tree.String())
+	}
+}
+
+// Write this node and everything below it, one node per line.
+func (node *node) writeIndented(buffer *bytes.Buffer, depth int) {
+	fmt.Fprintf(buffer, "%s%s %d/%d\n", strings.Repeat("  ", depth),
+		node.keyString(), node.currentSize, node.maxSize)
+	if !node.isLeaf {
+		for i := 0; i <= node.currentSize; i++ {
+			node.children[i].writeIndented(buffer, depth+1)
+		}
+	}
+}
+
+// The keys in this node, leaving out the unused item slots.
+func (node *node) keyString() string {
+	keys := make([]string, node.currentSize)
+	for i := 0; i < node.currentSize; i++ {
+		keys[i] = fmt.Sprint(node.items[i].key)
+	}
+	return "[" + strings.Join(keys, " ") + "]"
+}
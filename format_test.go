@@ -0,0 +1,38 @@
+package BTree
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Test the compact level by level layout.
+func Test_String(t *testing.T) {
+	tree := buildTree("tree", []int{1, 2, 3, 4, 5})
+	if tree.String() != "[3]\n[1 2] [4 5]" {
+		t.Error("wrong string:", tree.String())
+	}
+	if fmt.Sprintf("%v", tree) != tree.String() || fmt.Sprintf("%s", tree) != tree.String() {
+		t.Error("wrong formatting:", fmt.Sprintf("%v", tree))
+	}
+	if NewBTree(2).String() != "[]" {
+		t.Error("wrong string for an empty tree:", NewBTree(2).String())
+	}
+
+	tree = buildTree("tree", []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13})
+	expected := "[3 6 9]\n[1 2] [4 5] [7 8] [10 11 12 13]"
+	if tree.String() != expected {
+		t.Error("wrong string:", tree.String())
+	}
+}
+
+// Test the indented layout.
+func Test_FormatIndented(t *testing.T) {
+	tree := buildTree("tree", []int{1, 2, 3, 4, 5})
+	expected := "[3] 1/4\n  [1 2] 2/4\n  [4 5] 2/4"
+	if fmt.Sprintf("%+v", tree) != expected {
+		t.Error("wrong formatting:", fmt.Sprintf("%+v", tree))
+	}
+	if fmt.Sprintf("%d", tree) != "%!d(*BTree=[3]\n[1 2] [4 5])" {
+		t.Error("wrong formatting for a bad verb:", fmt.Sprintf("%d", tree))
+	}
+}
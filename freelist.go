@@ -0,0 +1,70 @@
+package BTree
+
+// Nodes which are no longer used by a tree, kept so that they (and more
+// importantly their items and children slices) can be reused when new
+// nodes are needed rather than allocating new ones. A nil free list is
+// fine to use, and just allocates new nodes and drops unused ones.
+type freeList struct {
+	// The most nodes (and children slices) to keep around.
+	size  int
+	nodes []*node
+	// Children slices are kept separately since leaves don't have them.
+	children [][]*node
+}
+
+// Create an empty free list which will keep up to size nodes.
+func newFreeList(size int) *freeList {
+	return &freeList{size, make([]*node, 0, size), make([][]*node, 0, size)}
+}
+
+// Get an empty node, reusing an old one if there are any.
+func (f *freeList) newNode(isLeaf bool, maxSize int, parent *node) *node {
+	var n *node
+	if f != nil && len(f.nodes) > 0 {
+		n = f.nodes[len(f.nodes)-1]
+		f.nodes = f.nodes[:len(f.nodes)-1]
+		n.isLeaf = isLeaf
+		n.parent = parent
+	} else {
+		n = &node{isLeaf, maxSize, 0, parent, make([]item, maxSize+1), nil, f, nil}
+	}
+	if !isLeaf {
+		n.children = f.newChildren(maxSize)
+	}
+	return n
+}
+
+// Get an empty children slice for an internal node, reusing an old one if
+// there are any.
+func (f *freeList) newChildren(maxSize int) []*node {
+	if f != nil && len(f.children) > 0 {
+		children := f.children[len(f.children)-1]
+		f.children = f.children[:len(f.children)-1]
+		return children
+	}
+	return make([]*node, maxSize+2)
+}
+
+// Take back a node which the tree no longer uses. Everything it points to
+// is cleared first so that the free list doesn't keep old values or nodes
+// from being garbage collected.
+func (f *freeList) freeNode(n *node) {
+	if f == nil {
+		return
+	}
+	if n.children != nil && len(f.children) < f.size {
+		for i := range n.children {
+			n.children[i] = nil
+		}
+		f.children = append(f.children, n.children)
+	}
+	n.children = nil
+	if len(f.nodes) < f.size {
+		for i := range n.items {
+			n.items[i] = item{0, nil}
+		}
+		n.currentSize = 0
+		n.parent = nil
+		f.nodes = append(f.nodes, n)
+	}
+}
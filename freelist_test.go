@@ -0,0 +1,97 @@
+package BTree
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// Test that nodes are given back to the free list when removals merge
+// them, and are reused by later insertions.
+func Test_FreeListReuse(t *testing.T) {
+	tree := NewBTreeWithFreeList(2, 100)
+	for i := 0; i < 200; i++ {
+		tree.Insert(i, fmt.Sprintf("foo: %d", i))
+	}
+	nodes := tree.Stats().Nodes
+	for i := 0; i < 150; i++ {
+		tree.Remove(i)
+		checkNode(t, tree.root, true)
+	}
+	freed := len(tree.free.nodes)
+	if freed == 0 || freed+tree.Stats().Nodes != nodes {
+		t.Error("removed nodes were not freed:", freed, tree.Stats().Nodes, nodes)
+	}
+	for _, n := range tree.free.nodes {
+		for _, slot := range n.items {
+			if slot.value != nil {
+				t.Error("free node still holds a value:", n.items)
+			}
+		}
+		if n.parent != nil || n.children != nil || n.currentSize != 0 {
+			t.Error("free node was not cleared:", n)
+		}
+	}
+	for _, children := range tree.free.children {
+		for _, child := range children {
+			if child != nil {
+				t.Error("free children slice was not cleared:", children)
+			}
+		}
+	}
+
+	for i := 0; i < 150; i++ {
+		tree.Insert(i, fmt.Sprintf("bar: %d", i))
+		checkNode(t, tree.root, true)
+	}
+	if len(tree.free.nodes) >= freed {
+		t.Error("free nodes were not reused:", len(tree.free.nodes), freed)
+	}
+	if tree.Size() != 200 || tree.Search(10) != "bar: 10" || tree.Search(190) != "foo: 190" {
+		t.Error("tree has the wrong items:", tree.Size(), tree.Search(10), tree.Search(190))
+	}
+}
+
+// Test that the free list doesn't grow past its size.
+func Test_FreeListSize(t *testing.T) {
+	tree := NewBTreeWithFreeList(1, 3)
+	for i := 0; i < 100; i++ {
+		tree.Insert(i, i)
+	}
+	for i := 0; i < 100; i++ {
+		tree.Remove(i)
+	}
+	if len(tree.free.nodes) != 3 || len(tree.free.children) > 3 {
+		t.Error("free list is too big:", len(tree.free.nodes), len(tree.free.children))
+	}
+	if tree.Size() != 0 {
+		t.Error("tree has wrong size:", tree.Size())
+	}
+}
+
+// Insert and remove random keys from a tree which stays about the same
+// size, which is where the free list should help.
+func benchmarkChurn(b *testing.B, tree *BTree) {
+	random := rand.New(rand.NewSource(1))
+	keys := make([]int, 10000)
+	for i := range keys {
+		keys[i] = random.Int()
+		tree.Insert(keys[i], i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		i := random.Intn(len(keys))
+		tree.Remove(keys[i])
+		keys[i] = random.Int()
+		tree.Insert(keys[i], n)
+	}
+}
+
+func BenchmarkChurn(b *testing.B) {
+	benchmarkChurn(b, NewBTree(8))
+}
+
+func BenchmarkChurnFreeList(b *testing.B) {
+	benchmarkChurn(b, NewBTreeWithFreeList(8, 64))
+}
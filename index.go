@@ -0,0 +1,162 @@
+package BTree
+
+// A tree of values keyed by unique ids, along with any number of named
+// secondary indexes which are kept up to date as the values change. Each
+// index has an extractor function which picks the secondary key out of a
+// value, and many values may share the same secondary key.
+type IndexedTree struct {
+	dimension int
+	primary   *BTree
+	indexes   map[string]*secondaryIndex
+}
+
+type secondaryIndex struct {
+	extract func(value interface{}) int
+	// Keyed by the secondary key, with each value being a tree of the ids
+	// with that secondary key (with nil values), so that the ids for a
+	// key come out in order and single ids can be removed.
+	tree *BTree
+}
+
+// Create a new IndexedTree with no secondary indexes, where the primary
+// tree and all of the index trees have the given dimension.
+func NewIndexedTree(dimension int) *IndexedTree {
+	return &IndexedTree{dimension, NewBTree(dimension), make(map[string]*secondaryIndex)}
+}
+
+// Add a secondary index with the given name, where extract returns the
+// secondary key for a value. The index is built from the values already in
+// the tree. Panics if there is already an index with the name.
+func (tree *IndexedTree) AddIndex(name string, extract func(value interface{}) int) {
+	if _, ok := tree.indexes[name]; ok {
+		panic("BTree: IndexedTree already has an index named " + name)
+	}
+	index := &secondaryIndex{extract, NewBTree(tree.dimension)}
+	tree.primary.Ascend(func(id int, value interface{}) bool {
+		index.add(tree.dimension, id, value)
+		return true
+	})
+	tree.indexes[name] = index
+}
+
+// Add a value with the given id, replacing the value if the id is already
+// in the tree.
+func (tree *IndexedTree) Insert(id int, value interface{}) {
+	if !tree.Update(id, value) {
+		tree.primary.Insert(id, value)
+		for _, index := range tree.indexes {
+			index.add(tree.dimension, id, value)
+		}
+	}
+}
+
+// Replace the value with the given id, moving it within each index whose
+// secondary key has changed. Returns false, without changing anything, if
+// the id is not in the tree.
+func (tree *IndexedTree) Update(id int, value interface{}) bool {
+	found, i := tree.primary.root.find(id)
+	if found == nil {
+		return false
+	}
+	old := found.items[i].value
+	found.items[i].value = value
+	for _, index := range tree.indexes {
+		if index.extract(old) != index.extract(value) {
+			index.remove(id, old)
+			index.add(tree.dimension, id, value)
+		}
+	}
+	return true
+}
+
+// Remove the value with the given id from the tree and all of the indexes,
+// and return it. If the id is not found, nil will be returned.
+func (tree *IndexedTree) Remove(id int) interface{} {
+	found, i := tree.primary.root.find(id)
+	if found == nil {
+		return nil
+	}
+	value := found.items[i].value
+	found.removeAt(i)
+	for _, index := range tree.indexes {
+		index.remove(id, value)
+	}
+	return value
+}
+
+// Find the value with the given id, or nil if the id is not found.
+func (tree *IndexedTree) Search(id int) interface{} {
+	return tree.primary.Search(id)
+}
+
+// Determine the number of values in the tree.
+func (tree *IndexedTree) Size() int {
+	return tree.primary.Size()
+}
+
+// Call fn for each id and value in the tree in order of id. Stops early if
+// fn returns false.
+func (tree *IndexedTree) Ascend(fn func(id int, value interface{}) bool) {
+	tree.primary.Ascend(fn)
+}
+
+// Find the ids of the values with the given secondary key in the named
+// index, in sorted order. Panics if there is no index with the name.
+func (tree *IndexedTree) LookupBy(name string, key int) []int {
+	ids := make([]int, 0)
+	// Seek the key itself rather than scanning up to key+1, which would
+	// overflow for math.MaxInt.
+	set, _ := tree.index(name).tree.Search(key).(*BTree)
+	if set != nil {
+		set.Ascend(func(id int, _ interface{}) bool {
+			ids = append(ids, id)
+			return true
+		})
+	}
+	return ids
+}
+
+// Call fn for each value with a secondary key in the range [start, end) in
+// the named index, ordered by secondary key and then id. Stops early if fn
+// returns false. Panics if there is no index with the name.
+func (tree *IndexedTree) AscendIndexRange(name string, start, end int, fn func(key, id int, value interface{}) bool) {
+	index := tree.index(name)
+	index.tree.AscendRange(start, end, func(key int, ids interface{}) bool {
+		more := true
+		ids.(*BTree).Ascend(func(id int, _ interface{}) bool {
+			more = fn(key, id, tree.primary.Search(id))
+			return more
+		})
+		return more
+	})
+}
+
+func (tree *IndexedTree) index(name string) *secondaryIndex {
+	index, ok := tree.indexes[name]
+	if !ok {
+		panic("BTree: IndexedTree has no index named " + name)
+	}
+	return index
+}
+
+// Add an id to the set of ids for the value's secondary key.
+func (index *secondaryIndex) add(dimension int, id int, value interface{}) {
+	key := index.extract(value)
+	ids, _ := index.tree.Search(key).(*BTree)
+	if ids == nil {
+		ids = NewBTree(dimension)
+		index.tree.Insert(key, ids)
+	}
+	ids.Insert(id, nil)
+}
+
+// Remove an id from the set of ids for the value's secondary key, dropping
+// the key once there are no ids left for it.
+func (index *secondaryIndex) remove(id int, value interface{}) {
+	key := index.extract(value)
+	ids := index.tree.Search(key).(*BTree)
+	ids.Remove(id)
+	if ids.Size() == 0 {
+		index.tree.Remove(key)
+	}
+}
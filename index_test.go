@@ -0,0 +1,138 @@
+package BTree
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+type person struct {
+	name string
+	age  int
+	city int
+}
+
+func byAge(value interface{}) int  { return value.(person).age }
+func byCity(value interface{}) int { return value.(person).city }
+
+func newPeople() *IndexedTree {
+	tree := NewIndexedTree(2)
+	tree.AddIndex("age", byAge)
+	tree.Insert(1, person{"ann", 30, 10})
+	tree.Insert(2, person{"bob", 25, 20})
+	tree.Insert(3, person{"cat", 30, 20})
+	tree.Insert(4, person{"dan", 40, 10})
+	// Indexes added later are built from the values already there.
+	tree.AddIndex("city", byCity)
+	return tree
+}
+
+func Test_IndexedTreeLookup(t *testing.T) {
+	tree := newPeople()
+	if ids := tree.LookupBy("age", 30); !reflect.DeepEqual(ids, []int{1, 3}) {
+		t.Error("wrong ids for age 30:", ids)
+	}
+	if ids := tree.LookupBy("city", 20); !reflect.DeepEqual(ids, []int{2, 3}) {
+		t.Error("wrong ids for city 20:", ids)
+	}
+	if ids := tree.LookupBy("age", 99); len(ids) != 0 {
+		t.Error("found ids for a missing age:", ids)
+	}
+
+	// The largest and smallest keys can be looked up too.
+	tree.Insert(5, person{"eve", math.MaxInt, math.MinInt})
+	tree.Insert(6, person{"fay", math.MaxInt - 1, math.MinInt})
+	if ids := tree.LookupBy("age", math.MaxInt); !reflect.DeepEqual(ids, []int{5}) {
+		t.Error("wrong ids for the largest age:", ids)
+	}
+	if ids := tree.LookupBy("city", math.MinInt); !reflect.DeepEqual(ids, []int{5, 6}) {
+		t.Error("wrong ids for the smallest city:", ids)
+	}
+	tree.Remove(5)
+	tree.Remove(6)
+
+	names := make([]string, 0)
+	tree.AscendIndexRange("age", 26, 41, func(key, id int, value interface{}) bool {
+		names = append(names, value.(person).name)
+		return true
+	})
+	if !reflect.DeepEqual(names, []string{"ann", "cat", "dan"}) {
+		t.Error("wrong range over ages:", names)
+	}
+}
+
+func Test_IndexedTreeChanges(t *testing.T) {
+	tree := newPeople()
+	// Moving someone changes the city index but leaves the age index.
+	if !tree.Update(1, person{"ann", 30, 20}) {
+		t.Error("failed to update an id in the tree")
+	}
+	if ids := tree.LookupBy("city", 20); !reflect.DeepEqual(ids, []int{1, 2, 3}) {
+		t.Error("wrong ids after update:", ids)
+	}
+	if ids := tree.LookupBy("city", 10); !reflect.DeepEqual(ids, []int{4}) {
+		t.Error("wrong ids after update:", ids)
+	}
+	if tree.Update(9, person{"eve", 1, 1}) || tree.Size() != 4 {
+		t.Error("updated an id not in the tree")
+	}
+
+	// Inserting an existing id replaces it.
+	tree.Insert(2, person{"bob", 31, 20})
+	if tree.Size() != 4 || len(tree.LookupBy("age", 25)) != 0 {
+		t.Error("insert did not replace the old value")
+	}
+
+	if value := tree.Remove(3); value.(person).name != "cat" {
+		t.Error("removed the wrong value:", value)
+	}
+	if ids := tree.LookupBy("age", 30); !reflect.DeepEqual(ids, []int{1}) {
+		t.Error("wrong ids after remove:", ids)
+	}
+	if tree.Remove(3) != nil || tree.Search(3) != nil {
+		t.Error("found a removed id")
+	}
+}
+
+// Check that the indexes match a scan of the primary tree after a random
+// mix of changes.
+func Test_IndexedTreeRandom(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	tree := NewIndexedTree(2)
+	tree.AddIndex("age", byAge)
+	for i := 0; i < 5000; i++ {
+		id := random.Intn(200)
+		if random.Intn(3) == 0 {
+			tree.Remove(id)
+		} else {
+			tree.Insert(id, person{"", random.Intn(20), 0})
+		}
+	}
+
+	expected := make(map[int][]int)
+	tree.Ascend(func(id int, value interface{}) bool {
+		expected[byAge(value)] = append(expected[byAge(value)], id)
+		return true
+	})
+	count := 0
+	for age := 0; age < 20; age++ {
+		ids := tree.LookupBy("age", age)
+		count += len(ids)
+		if len(ids) != len(expected[age]) || (len(ids) > 0 && !reflect.DeepEqual(ids, expected[age])) {
+			t.Error("wrong ids for age", age, ids, expected[age])
+		}
+	}
+	if count != tree.Size() {
+		t.Error("index has the wrong number of ids:", count, tree.Size())
+	}
+}
+
+func Test_IndexedTreeUnknownIndex(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("looked up an index which does not exist")
+		}
+	}()
+	newPeople().LookupBy("name", 1)
+}
@@ -0,0 +1,204 @@
+/*
+Package keys encodes tuples of values into byte strings which sort in the
+same order as the tuples, so that bytes.Compare on two encoded keys gives
+the same answer as comparing the tuples element by element. This makes it
+possible to build composite keys like (tenant, timestamp, id).
+
+The encoding of a tuple is also a prefix of the encoding of any longer
+tuple which starts with the same elements, so all of the keys starting
+with some elements can be found by scanning from Encode(elements...) up to
+TupleEnd of it. Note that PrefixEnd is not the right end for this: the
+encoding of ("a") is a byte prefix of the encoding of ("a\x00b") too, since
+the zero byte is escaped, so a byte prefix scan would include it.
+
+Note that the BTree itself still only takes int keys, so these are for use
+with byte string keys (see the TODO list in the README).
+
+Each element is a type code followed by the encoded value:
+
+	bytes   0x01, the bytes with 0x00 escaped as 0x00 0xff, then 0x00
+	string  0x02, encoded the same way as bytes
+	int     0x03, 8 bytes big endian with the sign bit flipped
+	time    0x04, the Unix seconds encoded as an int, then 4 bytes of nanoseconds
+
+Elements of different types sort by their type codes, so for example all
+byte strings sort before all strings.
+*/
+package keys
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// The type codes at the start of each encoded element.
+const (
+	bytesCode  = 0x01
+	stringCode = 0x02
+	intCode    = 0x03
+	timeCode   = 0x04
+)
+
+// Returned by Decode for byte strings which were not made by Encode.
+var ErrInvalid = errors.New("keys: invalid encoded key")
+
+// Encode a tuple of elements. Each element must be an int, int64, string,
+// []byte or time.Time.
+func Encode(elements ...interface{}) ([]byte, error) {
+	return Append(nil, elements...)
+}
+
+// Append the encoding of the elements to an existing key, as Encode.
+func Append(key []byte, elements ...interface{}) ([]byte, error) {
+	for _, element := range elements {
+		switch v := element.(type) {
+		case []byte:
+			key = AppendBytes(key, v)
+		case string:
+			key = AppendString(key, v)
+		case int:
+			key = AppendInt(key, int64(v))
+		case int64:
+			key = AppendInt(key, v)
+		case time.Time:
+			key = AppendTime(key, v)
+		default:
+			return nil, fmt.Errorf("keys: can't encode element of type %T", element)
+		}
+	}
+	return key, nil
+}
+
+// Append a byte string element to a key.
+func AppendBytes(key []byte, b []byte) []byte {
+	return appendEscaped(append(key, bytesCode), b)
+}
+
+// Append a string element to a key.
+func AppendString(key []byte, s string) []byte {
+	return appendEscaped(append(key, stringCode), []byte(s))
+}
+
+// Append an integer element to a key.
+func AppendInt(key []byte, i int64) []byte {
+	return appendInt(append(key, intCode), i)
+}
+
+// Append a time element to a key. Times are kept to the nanosecond, but
+// their locations are not kept.
+func AppendTime(key []byte, t time.Time) []byte {
+	key = appendInt(append(key, timeCode), t.Unix())
+	return binary.BigEndian.AppendUint32(key, uint32(t.Nanosecond()))
+}
+
+// Flipping the sign bit puts negative numbers before positive ones when
+// the bytes are compared.
+func appendInt(key []byte, i int64) []byte {
+	return binary.BigEndian.AppendUint64(key, uint64(i)^(1<<63))
+}
+
+// Append the bytes with a terminator after them. Any zero bytes inside
+// are followed by 0xff so that they sort after the terminator, which
+// makes shorter strings sort before longer ones that they are a prefix of.
+func appendEscaped(key []byte, b []byte) []byte {
+	for _, c := range b {
+		key = append(key, c)
+		if c == 0x00 {
+			key = append(key, 0xff)
+		}
+	}
+	return append(key, 0x00)
+}
+
+// Decode a key made by Encode back into its elements. Integers are always
+// returned as int64, and times are returned in UTC.
+func Decode(key []byte) ([]interface{}, error) {
+	elements := make([]interface{}, 0)
+	for len(key) > 0 {
+		code := key[0]
+		key = key[1:]
+		switch code {
+		case bytesCode, stringCode:
+			b, rest, err := decodeEscaped(key)
+			if err != nil {
+				return nil, err
+			}
+			if code == bytesCode {
+				elements = append(elements, b)
+			} else {
+				elements = append(elements, string(b))
+			}
+			key = rest
+		case intCode:
+			if len(key) < 8 {
+				return nil, ErrInvalid
+			}
+			elements = append(elements, decodeInt(key))
+			key = key[8:]
+		case timeCode:
+			if len(key) < 12 {
+				return nil, ErrInvalid
+			}
+			nanoseconds := binary.BigEndian.Uint32(key[8:])
+			if nanoseconds >= 1e9 {
+				return nil, ErrInvalid
+			}
+			elements = append(elements, time.Unix(decodeInt(key), int64(nanoseconds)).UTC())
+			key = key[12:]
+		default:
+			return nil, ErrInvalid
+		}
+	}
+	return elements, nil
+}
+
+func decodeInt(key []byte) int64 {
+	return int64(binary.BigEndian.Uint64(key) ^ (1 << 63))
+}
+
+// Undo appendEscaped, returning the bytes and whatever follows them.
+func decodeEscaped(key []byte) ([]byte, []byte, error) {
+	b := make([]byte, 0)
+	for i := 0; i < len(key); i++ {
+		if key[i] != 0x00 {
+			b = append(b, key[i])
+		} else if i+1 < len(key) && key[i+1] == 0xff {
+			b = append(b, 0x00)
+			i++
+		} else {
+			return b, key[i+1:], nil
+		}
+	}
+	return nil, nil, ErrInvalid
+}
+
+// Find the end of a scan over the tuples which start with the encoded
+// elements in the prefix, so that [prefix, TupleEnd(prefix)) holds exactly
+// those tuples. Every element starts with a type code below 0xff, so each
+// longer tuple sorts before the prefix followed by 0xff. A string which only
+// continues the last element of the prefix has an escaped zero byte, 0x00
+// 0xff, where the prefix has its terminator, so it sorts after the end.
+func TupleEnd(prefix []byte) []byte {
+	end := make([]byte, len(prefix)+1)
+	copy(end, prefix)
+	end[len(prefix)] = 0xff
+	return end
+}
+
+// Find the smallest key which is larger than every key starting with the
+// prefix, for the end of a byte prefix scan. Returns nil if there is no
+// such key, which is when the prefix is empty or all 0xff bytes. Use
+// TupleEnd instead to scan for the tuples starting with some elements.
+func PrefixEnd(prefix []byte) []byte {
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if prefix[i] != 0xff {
+			end := make([]byte, i+1)
+			copy(end, prefix)
+			end[i]++
+			return end
+		}
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package keys
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Compare two tuples element by element, ordering different types by
+// their type codes. Returns -1, 0 or 1.
+func compareTuples(a, b []interface{}) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := compareElements(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(a) < len(b):
+		return -1
+	case len(a) > len(b):
+		return 1
+	}
+	return 0
+}
+
+func typeCode(element interface{}) int {
+	switch element.(type) {
+	case []byte:
+		return bytesCode
+	case string:
+		return stringCode
+	case int64:
+		return intCode
+	}
+	return timeCode
+}
+
+func compareElements(a, b interface{}) int {
+	if typeCode(a) != typeCode(b) {
+		if typeCode(a) < typeCode(b) {
+			return -1
+		}
+		return 1
+	}
+	switch v := a.(type) {
+	case []byte:
+		return bytes.Compare(v, b.([]byte))
+	case string:
+		return strings.Compare(v, b.(string))
+	case int64:
+		switch {
+		case v < b.(int64):
+			return -1
+		case v > b.(int64):
+			return 1
+		}
+		return 0
+	}
+	t, u := a.(time.Time), b.(time.Time)
+	switch {
+	case t.Before(u):
+		return -1
+	case t.After(u):
+		return 1
+	}
+	return 0
+}
+
+// Pick a random element, from a small set of values so that tuples often
+// share prefixes.
+func randomElement(random *rand.Rand) interface{} {
+	strs := []string{"", "a", "a\x00", "a\x00b", "ab", "b", "\x00", "\xff", "\x00\xff"}
+	ints := []int64{math.MinInt64, -1000, -1, 0, 1, 255, 256, 1000, math.MaxInt64}
+	switch random.Intn(4) {
+	case 0:
+		return []byte(strs[random.Intn(len(strs))])
+	case 1:
+		return strs[random.Intn(len(strs))]
+	case 2:
+		return ints[random.Intn(len(ints))]
+	}
+	return time.Unix(ints[random.Intn(len(ints))]/1000, random.Int63n(3)*5e8).UTC()
+}
+
+// Test that encoded keys sort in the same order as their tuples.
+func Test_Order(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	tuples := make([][]interface{}, 2000)
+	for i := range tuples {
+		tuples[i] = make([]interface{}, random.Intn(4))
+		for j := range tuples[i] {
+			tuples[i][j] = randomElement(random)
+		}
+	}
+	sort.Slice(tuples, func(i, j int) bool { return compareTuples(tuples[i], tuples[j]) < 0 })
+
+	encoded := make([][]byte, len(tuples))
+	for i, tuple := range tuples {
+		key, err := Encode(tuple...)
+		if err != nil {
+			t.Fatal("Encode failed:", tuple, err)
+		}
+		encoded[i] = key
+	}
+	for i := 1; i < len(encoded); i++ {
+		want := compareTuples(tuples[i-1], tuples[i])
+		if got := bytes.Compare(encoded[i-1], encoded[i]); got != want {
+			t.Error("keys are out of order:", tuples[i-1], tuples[i], encoded[i-1], encoded[i])
+		}
+	}
+}
+
+// Test decoding keys back into their elements.
+func Test_RoundTrip(t *testing.T) {
+	when := time.Date(2020, 2, 29, 12, 30, 0, 123456789, time.UTC)
+	tuple := []interface{}{[]byte("a\x00b"), "tenant", int64(-42), when, "", []byte{}}
+	key, err := Encode(tuple...)
+	if err != nil {
+		t.Fatal("Encode failed:", err)
+	}
+	decoded, err := Decode(key)
+	if err != nil {
+		t.Fatal("Decode failed:", err)
+	}
+	if !reflect.DeepEqual(decoded, tuple) {
+		t.Error("wrong elements decoded:", decoded, tuple)
+	}
+
+	// Plain ints come back as int64.
+	key, _ = Encode(7)
+	decoded, _ = Decode(key)
+	if len(decoded) != 1 || decoded[0] != int64(7) {
+		t.Error("wrong int decoded:", decoded)
+	}
+}
+
+// Test that shorter tuples are prefixes of longer ones, and that prefix
+// scans cover exactly the keys with the prefix.
+func Test_Prefix(t *testing.T) {
+	prefix, _ := Encode("tenant", int64(5))
+	key, _ := Encode("tenant", int64(5), "id")
+	if !bytes.HasPrefix(key, prefix) {
+		t.Error("tuple is not a prefix:", prefix, key)
+	}
+	end := TupleEnd(prefix)
+	if bytes.Compare(key, end) >= 0 || bytes.Compare(prefix, end) >= 0 {
+		t.Error("key is past the end of the prefix:", key, end)
+	}
+	next, _ := Encode("tenant", int64(6))
+	if bytes.Compare(next, end) < 0 {
+		t.Error("key is before the end of the prefix:", next, end)
+	}
+
+	// A string with a zero byte in it is a byte prefix of the encoding of a
+	// shorter string, but it is not in the shorter string's tuple range.
+	prefix, _ = Encode("tenant")
+	end = TupleEnd(prefix)
+	for _, key := range [][]interface{}{{"tenant\x00x"}, {"tenant\x00"}, {"tenant\x00\xff"}, {"tenanta"}} {
+		encoded, _ := Encode(key...)
+		if bytes.Compare(encoded, prefix) >= 0 && bytes.Compare(encoded, end) < 0 {
+			t.Error("key is inside the prefix range:", key, encoded, end)
+		}
+	}
+	for _, key := range [][]interface{}{{"tenant"}, {"tenant", "\x00"}, {"tenant", []byte{0xff}},
+		{"tenant", int64(math.MaxInt64)}, {"tenant", time.Unix(1<<40, 999999999)}} {
+		encoded, _ := Encode(key...)
+		if bytes.Compare(encoded, prefix) < 0 || bytes.Compare(encoded, end) >= 0 {
+			t.Error("key is outside the prefix range:", key, encoded, end)
+		}
+	}
+
+	if !bytes.Equal(PrefixEnd([]byte{1, 0xff, 0xff}), []byte{2}) {
+		t.Error("wrong prefix end:", PrefixEnd([]byte{1, 0xff, 0xff}))
+	}
+	if PrefixEnd([]byte{0xff}) != nil || PrefixEnd(nil) != nil {
+		t.Error("found an end for a prefix without one")
+	}
+}
+
+// Test that bad input is rejected.
+func Test_Errors(t *testing.T) {
+	if _, err := Encode(1.5); err == nil {
+		t.Error("encoded a float")
+	}
+	for _, key := range [][]byte{{0x09}, {bytesCode, 'a'}, {intCode, 1, 2}, {timeCode, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 0xff, 0xff}} {
+		if _, err := Decode(key); err != ErrInvalid {
+			t.Error("decoded an invalid key:", key, err)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package BTree
+
+// Combine two trees into a new tree holding the union of their items.
+// When a key is found in both trees, resolve is called with the key and
+// both values to decide the value kept in the new tree. Neither input tree
+// is modified, and the new tree uses the dimension of the first tree.
+// Items added by InsertWithTTL which have expired are left out, as they
+// are by each of these functions, and the rest are kept without a ttl.
+func Merge(a, b *BTree, resolve func(key int, aValue, bValue interface{}) interface{}) *BTree {
+	results := make([]item, 0)
+	aItems, bItems := a.liveItems(), b.liveItems()
+	aItem, aOk := aItems.next()
+	bItem, bOk := bItems.next()
+	for aOk && bOk {
+		if aItem.key < bItem.key {
+			results = append(results, aItem)
+			aItem, aOk = aItems.next()
+		} else if bItem.key < aItem.key {
+			results = append(results, bItem)
+			bItem, bOk = bItems.next()
+		} else {
+			results = append(results, item{aItem.key, resolve(aItem.key, aItem.value, bItem.value)})
+			aItem, aOk = aItems.next()
+			bItem, bOk = bItems.next()
+		}
+	}
+	// At most one of these will have anything left.
+	for ; aOk; aItem, aOk = aItems.next() {
+		results = append(results, aItem)
+	}
+	for ; bOk; bItem, bOk = bItems.next() {
+		results = append(results, bItem)
+	}
+	return newBTreeFromItems(a.dimension, results)
+}
+
+// Create a new tree holding only the keys found in both trees. As with
+// Merge, resolve decides the value kept for each key.
+func Intersect(a, b *BTree, resolve func(key int, aValue, bValue interface{}) interface{}) *BTree {
+	results := make([]item, 0)
+	aItems, bItems := a.liveItems(), b.liveItems()
+	aItem, aOk := aItems.next()
+	bItem, bOk := bItems.next()
+	for aOk && bOk {
+		if aItem.key < bItem.key {
+			aItem, aOk = aItems.next()
+		} else if bItem.key < aItem.key {
+			bItem, bOk = bItems.next()
+		} else {
+			results = append(results, item{aItem.key, resolve(aItem.key, aItem.value, bItem.value)})
+			aItem, aOk = aItems.next()
+			bItem, bOk = bItems.next()
+		}
+	}
+	return newBTreeFromItems(a.dimension, results)
+}
+
+// Create a new tree holding the items from the first tree whose keys are
+// not found in the second tree.
+func Difference(a, b *BTree) *BTree {
+	results := make([]item, 0)
+	aItems, bItems := a.liveItems(), b.liveItems()
+	aItem, aOk := aItems.next()
+	bItem, bOk := bItems.next()
+	for aOk {
+		// Skip past the keys in b which are smaller than the current key.
+		for bOk && bItem.key < aItem.key {
+			bItem, bOk = bItems.next()
+		}
+		if !bOk || aItem.key != bItem.key {
+			results = append(results, aItem)
+		}
+		aItem, aOk = aItems.next()
+	}
+	return newBTreeFromItems(a.dimension, results)
+}
@@ -0,0 +1,114 @@
+package BTree
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Build a tree holding the given keys, with values naming the tree.
+func buildTree(name string, keys []int) *BTree {
+	tree := NewBTree(2)
+	for _, key := range keys {
+		tree.Insert(key, fmt.Sprintf("%s: %d", name, key))
+	}
+	return tree
+}
+
+// Check that the tree holds exactly the expected keys in order.
+func checkKeys(t *testing.T, tree *BTree, expectedKeys []int) {
+	keys := tree.root.keyTraversal()
+	if len(keys) != len(expectedKeys) {
+		t.Error("weird keys length: ", keys, expectedKeys)
+		return
+	}
+	for i := 0; i < len(keys); i++ {
+		if keys[i] != expectedKeys[i] {
+			t.Error("weird key values: ", keys, expectedKeys)
+			return
+		}
+	}
+}
+
+// Join the two values together so that we can tell resolve was called.
+func joinValues(key int, aValue, bValue interface{}) interface{} {
+	return fmt.Sprintf("%v + %v", aValue, bValue)
+}
+
+// Test merging two trees with some keys in common.
+func Test_Merge(t *testing.T) {
+	a := buildTree("a", []int{1, 3, 5, 7, 9, 11, 13})
+	b := buildTree("b", []int{2, 3, 4, 9, 20})
+	merged := Merge(a, b, joinValues)
+	checkNode(t, merged.root, true)
+	checkKeys(t, merged, []int{1, 2, 3, 4, 5, 7, 9, 11, 13, 20})
+	if merged.Search(1) != "a: 1" {
+		t.Error("Wrong value for key 1:", merged.Search(1))
+	}
+	if merged.Search(20) != "b: 20" {
+		t.Error("Wrong value for key 20:", merged.Search(20))
+	}
+	if merged.Search(9) != "a: 9 + b: 9" {
+		t.Error("Wrong value for key 9:", merged.Search(9))
+	}
+
+	// The original trees are left alone.
+	checkKeys(t, a, []int{1, 3, 5, 7, 9, 11, 13})
+	checkKeys(t, b, []int{2, 3, 4, 9, 20})
+	if a.Search(9) != "a: 9" {
+		t.Error("Merge changed the first tree:", a.Search(9))
+	}
+
+	// Merging with an empty tree just copies the other one.
+	checkKeys(t, Merge(NewBTree(2), b, joinValues), []int{2, 3, 4, 9, 20})
+	checkKeys(t, Merge(a, NewBTree(2), joinValues), []int{1, 3, 5, 7, 9, 11, 13})
+}
+
+// Test merging larger trees so that the result has several levels.
+func Test_MergeMany(t *testing.T) {
+	aKeys, bKeys, expectedKeys := make([]int, 0), make([]int, 0), make([]int, 0)
+	for i := 0; i < 300; i++ {
+		if i%2 == 0 {
+			aKeys = append(aKeys, i)
+		}
+		if i%3 == 0 {
+			bKeys = append(bKeys, i)
+		}
+		if i%2 == 0 || i%3 == 0 {
+			expectedKeys = append(expectedKeys, i)
+		}
+	}
+	merged := Merge(buildTree("a", aKeys), buildTree("b", bKeys), joinValues)
+	checkNode(t, merged.root, true)
+	checkKeys(t, merged, expectedKeys)
+	if merged.Search(42) != "a: 42 + b: 42" {
+		t.Error("Wrong value for key 42:", merged.Search(42))
+	}
+}
+
+// Test keeping just the keys the trees have in common.
+func Test_Intersect(t *testing.T) {
+	a := buildTree("a", []int{1, 3, 5, 7, 9, 11, 13})
+	b := buildTree("b", []int{2, 3, 4, 9, 20})
+	both := Intersect(a, b, joinValues)
+	checkNode(t, both.root, true)
+	checkKeys(t, both, []int{3, 9})
+	if both.Search(3) != "a: 3 + b: 3" {
+		t.Error("Wrong value for key 3:", both.Search(3))
+	}
+	checkKeys(t, Intersect(a, NewBTree(2), joinValues), []int{})
+}
+
+// Test removing the keys of one tree from another.
+func Test_Difference(t *testing.T) {
+	a := buildTree("a", []int{1, 3, 5, 7, 9, 11, 13})
+	b := buildTree("b", []int{2, 3, 4, 9, 20})
+	onlyA := Difference(a, b)
+	checkNode(t, onlyA.root, true)
+	checkKeys(t, onlyA, []int{1, 5, 7, 11, 13})
+	if onlyA.Search(5) != "a: 5" {
+		t.Error("Wrong value for key 5:", onlyA.Search(5))
+	}
+	checkKeys(t, Difference(b, a), []int{2, 4, 20})
+	checkKeys(t, Difference(a, NewBTree(2)), []int{1, 3, 5, 7, 9, 11, 13})
+	checkKeys(t, Difference(NewBTree(2), a), []int{})
+}
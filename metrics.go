@@ -0,0 +1,360 @@
+package BTree
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// The operations which are counted and timed.
+type operation int
+
+const (
+	insertOperation operation = iota
+	searchOperation
+	removeOperation
+	operationCount
+)
+
+// The upper bounds of the latency histogram buckets. Anything slower goes
+// in one more bucket at the end.
+var latencyBounds = [...]time.Duration{
+	100 * time.Nanosecond, 250 * time.Nanosecond, 500 * time.Nanosecond,
+	time.Microsecond, 2500 * time.Nanosecond, 5 * time.Microsecond,
+	10 * time.Microsecond, 25 * time.Microsecond, 50 * time.Microsecond,
+	100 * time.Microsecond, 250 * time.Microsecond, 500 * time.Microsecond,
+	time.Millisecond, 10 * time.Millisecond, 100 * time.Millisecond,
+}
+
+// Counters, gauges and latency histograms for a tree, which can be read
+// while the tree is in use from another goroutine. Every method is safe to
+// call on a nil Metrics, and does nothing, so that trees without metrics
+// enabled only pay for a nil check.
+type Metrics struct {
+	name       string
+	operations [operationCount]atomic.Uint64
+	latencies  [operationCount]histogram
+	splits     atomic.Uint64
+	merges     atomic.Uint64
+	// Kept up to date as the tree changes, rather than by walking it.
+	size   atomic.Int64
+	height atomic.Int64
+	nodes  atomic.Int64
+}
+
+type histogram struct {
+	counts [len(latencyBounds) + 1]atomic.Uint64
+	// The total of all of the latencies, in nanoseconds.
+	sum atomic.Uint64
+}
+
+// The values of a tree's metrics at some point in time. This is what is
+// published through expvar.
+type MetricsSnapshot struct {
+	Name string
+	// Items inserted (including by InsertBatch and InsertWithTTL), and
+	// calls to Search and Remove.
+	Inserts  uint64
+	Searches uint64
+	Removes  uint64
+	// Nodes split by insertions and merged by removals.
+	Splits uint64
+	Merges uint64
+	// The number of items (including expired ones not yet swept), levels
+	// and nodes in the tree.
+	Size   int64
+	Height int64
+	Nodes  int64
+	// InsertBatch is counted in Inserts but not timed, since its latency
+	// covers many items.
+	InsertLatency LatencySnapshot
+	SearchLatency LatencySnapshot
+	RemoveLatency LatencySnapshot
+}
+
+// A latency histogram, with times in seconds.
+type LatencySnapshot struct {
+	// The upper bounds of the buckets. Counts has one more entry than this,
+	// for the latencies above the last bound.
+	Bounds []float64
+	Counts []uint64
+	Count  uint64
+	Sum    float64
+}
+
+// Start collecting metrics for the tree, which is given a name to tell it
+// apart from others when the metrics are exported. Returns the existing
+// metrics if they are already enabled.
+func (tree *BTree) EnableMetrics(name string) *Metrics {
+	if tree.metrics == nil {
+		tree.metrics = &Metrics{name: name}
+		tree.metrics.attach(tree.root)
+	}
+	return tree.metrics
+}
+
+// Get the tree's metrics, or nil if they are not enabled.
+func (tree *BTree) Metrics() *Metrics {
+	return tree.metrics
+}
+
+// Point all of the nodes under the root at these metrics, and set the
+// gauges from what is found there.
+func (m *Metrics) attach(root *node) {
+	if m == nil {
+		return
+	}
+	size, height, nodes := 0, 0, 0
+	level := []*node{root}
+	for len(level) > 0 {
+		height++
+		next := make([]*node, 0)
+		for _, n := range level {
+			n.metrics = m
+			size += n.currentSize
+			nodes++
+			if !n.isLeaf {
+				next = append(next, n.children[:n.currentSize+1]...)
+			}
+		}
+		level = next
+	}
+	m.size.Store(int64(size))
+	m.height.Store(int64(height))
+	m.nodes.Store(int64(nodes))
+}
+
+// Get the time an operation starts, if it is going to be timed.
+func (m *Metrics) start() time.Time {
+	if m == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// Count an operation and record how long it took since start.
+func (m *Metrics) finish(op operation, start time.Time) {
+	if m == nil {
+		return
+	}
+	m.operations[op].Add(1)
+	m.latencies[op].observe(time.Since(start))
+}
+
+// Count operations without timing them.
+func (m *Metrics) count(op operation, n int) {
+	if m != nil {
+		m.operations[op].Add(uint64(n))
+	}
+}
+
+// Record items being added to or removed from the tree.
+func (m *Metrics) resize(delta int) {
+	if m != nil {
+		m.size.Add(int64(delta))
+	}
+}
+
+// Record a node splitting. Splitting the root adds two nodes below it
+// rather than one beside it, making the tree taller.
+func (m *Metrics) split(isRoot bool) {
+	if m == nil {
+		return
+	}
+	m.splits.Add(1)
+	m.nodes.Add(1)
+	if isRoot {
+		m.nodes.Add(1)
+		m.height.Add(1)
+	}
+}
+
+// Record two nodes merging into one.
+func (m *Metrics) merge() {
+	if m != nil {
+		m.merges.Add(1)
+		m.nodes.Add(-1)
+	}
+}
+
+// Record the root's only child being moved up into it, making the tree
+// shorter.
+func (m *Metrics) collapse() {
+	if m != nil {
+		m.nodes.Add(-1)
+		m.height.Add(-1)
+	}
+}
+
+func (h *histogram) observe(latency time.Duration) {
+	i := 0
+	for i < len(latencyBounds) && latency > latencyBounds[i] {
+		i++
+	}
+	h.counts[i].Add(1)
+	h.sum.Add(uint64(latency))
+}
+
+func (h *histogram) snapshot() LatencySnapshot {
+	s := LatencySnapshot{
+		Bounds: make([]float64, len(latencyBounds)),
+		Counts: make([]uint64, len(h.counts)),
+		Sum:    time.Duration(h.sum.Load()).Seconds(),
+	}
+	for i, bound := range latencyBounds {
+		s.Bounds[i] = bound.Seconds()
+	}
+	for i := range h.counts {
+		s.Counts[i] = h.counts[i].Load()
+		s.Count += s.Counts[i]
+	}
+	return s
+}
+
+// Read the current values of the metrics. Each value is read atomically,
+// but the tree may change while they are being read, so they are not
+// necessarily consistent with each other.
+func (m *Metrics) Snapshot() MetricsSnapshot {
+	if m == nil {
+		return MetricsSnapshot{}
+	}
+	return MetricsSnapshot{
+		Name:          m.name,
+		Inserts:       m.operations[insertOperation].Load(),
+		Searches:      m.operations[searchOperation].Load(),
+		Removes:       m.operations[removeOperation].Load(),
+		Splits:        m.splits.Load(),
+		Merges:        m.merges.Load(),
+		Size:          m.size.Load(),
+		Height:        m.height.Load(),
+		Nodes:         m.nodes.Load(),
+		InsertLatency: m.latencies[insertOperation].snapshot(),
+		SearchLatency: m.latencies[searchOperation].snapshot(),
+		RemoveLatency: m.latencies[removeOperation].snapshot(),
+	}
+}
+
+// Publish the metrics through expvar, under the name given to
+// EnableMetrics. Like expvar.Publish, this panics if the name is already
+// in use.
+func (m *Metrics) Publish() {
+	expvar.Publish(m.name, expvar.Func(func() interface{} {
+		return m.Snapshot()
+	}))
+}
+
+// Create an HTTP handler which serves the metrics of the given trees in
+// the Prometheus text format, with each tree's name as the tree label.
+func PrometheusHandler(metrics ...*Metrics) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WritePrometheus(w, metrics...)
+	})
+}
+
+// Write the metrics of the given trees in the Prometheus text format.
+func WritePrometheus(w io.Writer, metrics ...*Metrics) error {
+	snapshots := make([]MetricsSnapshot, len(metrics))
+	for i, m := range metrics {
+		snapshots[i] = m.Snapshot()
+	}
+	p := &promWriter{w: w}
+
+	counters := []struct {
+		name, help string
+		value      func(s MetricsSnapshot) uint64
+	}{
+		{"inserts", "Items inserted into the tree.", func(s MetricsSnapshot) uint64 { return s.Inserts }},
+		{"searches", "Searches of the tree.", func(s MetricsSnapshot) uint64 { return s.Searches }},
+		{"removes", "Removes from the tree.", func(s MetricsSnapshot) uint64 { return s.Removes }},
+		{"splits", "Nodes split by insertions.", func(s MetricsSnapshot) uint64 { return s.Splits }},
+		{"merges", "Nodes merged by removals.", func(s MetricsSnapshot) uint64 { return s.Merges }},
+	}
+	for _, c := range counters {
+		p.header("btree_"+c.name+"_total", "counter", c.help)
+		for _, s := range snapshots {
+			p.sample("btree_"+c.name+"_total", s.Name, "", strconv.FormatUint(c.value(s), 10))
+		}
+	}
+
+	gauges := []struct {
+		name, help string
+		value      func(s MetricsSnapshot) int64
+	}{
+		{"size", "Items in the tree.", func(s MetricsSnapshot) int64 { return s.Size }},
+		{"height", "Levels in the tree.", func(s MetricsSnapshot) int64 { return s.Height }},
+		{"nodes", "Nodes in the tree.", func(s MetricsSnapshot) int64 { return s.Nodes }},
+	}
+	for _, g := range gauges {
+		p.header("btree_"+g.name, "gauge", g.help)
+		for _, s := range snapshots {
+			p.sample("btree_"+g.name, s.Name, "", strconv.FormatInt(g.value(s), 10))
+		}
+	}
+
+	histograms := []struct {
+		name, help string
+		value      func(s MetricsSnapshot) LatencySnapshot
+	}{
+		{"insert", "Time taken by Insert.", func(s MetricsSnapshot) LatencySnapshot { return s.InsertLatency }},
+		{"search", "Time taken by Search.", func(s MetricsSnapshot) LatencySnapshot { return s.SearchLatency }},
+		{"remove", "Time taken by Remove.", func(s MetricsSnapshot) LatencySnapshot { return s.RemoveLatency }},
+	}
+	for _, h := range histograms {
+		name := "btree_" + h.name + "_duration_seconds"
+		p.header(name, "histogram", h.help)
+		for _, s := range snapshots {
+			latency := h.value(s)
+			// Prometheus buckets count everything up to their bound.
+			total := uint64(0)
+			for i, count := range latency.Counts {
+				total += count
+				bound := "+Inf"
+				if i < len(latency.Bounds) {
+					bound = formatFloat(latency.Bounds[i])
+				}
+				p.sample(name+"_bucket", s.Name, bound, strconv.FormatUint(total, 10))
+			}
+			p.sample(name+"_sum", s.Name, "", formatFloat(latency.Sum))
+			p.sample(name+"_count", s.Name, "", strconv.FormatUint(latency.Count, 10))
+		}
+	}
+	return p.err
+}
+
+// Writes the Prometheus text format, keeping the first error.
+type promWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (p *promWriter) header(name, kind, help string) {
+	p.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// Write a sample with the tree label, and the le label if a bucket bound
+// is given.
+func (p *promWriter) sample(name, tree, bound, value string) {
+	labels := `tree="` + labelEscaper.Replace(tree) + `"`
+	if bound != "" {
+		labels += `,le="` + bound + `"`
+	}
+	p.printf("%s{%s} %s\n", name, labels, value)
+}
+
+func (p *promWriter) printf(format string, args ...interface{}) {
+	if p.err == nil {
+		_, p.err = fmt.Fprintf(p.w, format, args...)
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
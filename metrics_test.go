@@ -0,0 +1,158 @@
+package BTree
+
+import (
+	"bytes"
+	"encoding/json"
+	"expvar"
+	"math/rand"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Check the gauges against a walk of the whole tree.
+func checkGauges(t *testing.T, tree *BTree) {
+	s := tree.Metrics().Snapshot()
+	stats := tree.Stats()
+	if s.Size != int64(tree.Size()) || s.Height != int64(stats.Height) || s.Nodes != int64(stats.Nodes) {
+		t.Errorf("wrong gauges: size %d height %d nodes %d, expected %d %d %d",
+			s.Size, s.Height, s.Nodes, tree.Size(), stats.Height, stats.Nodes)
+	}
+}
+
+// The gauges are kept up to date through splits, merges and the root
+// growing and shrinking, with and without a free list.
+func Test_MetricsGauges(t *testing.T) {
+	for _, tree := range []*BTree{NewBTree(2), NewBTreeWithFreeList(2, 8)} {
+		random := rand.New(rand.NewSource(1))
+		tree.Insert(1, nil)
+		tree.EnableMetrics("test")
+		checkGauges(t, tree)
+		for i := 0; i < 5000; i++ {
+			if random.Intn(2) == 0 {
+				tree.Insert(random.Intn(500), i)
+			} else {
+				tree.Remove(random.Intn(500))
+			}
+		}
+		checkGauges(t, tree)
+		s := tree.Metrics().Snapshot()
+		if s.Splits == 0 || s.Merges == 0 {
+			t.Error("splits or merges not counted:", s.Splits, s.Merges)
+		}
+		for tree.Size() > 0 {
+			tree.Remove(tree.root.items[0].key)
+		}
+		checkGauges(t, tree)
+	}
+
+	// Batches into empty and non-empty trees.
+	tree := NewBTree(3)
+	tree.EnableMetrics("batch")
+	tree.InsertBatch(shuffledKeys(1000), make([]interface{}, 1000))
+	checkGauges(t, tree)
+	tree.InsertBatch(shuffledKeys(500), make([]interface{}, 500))
+	checkGauges(t, tree)
+	tree.Insert(5, nil)
+	checkGauges(t, tree)
+	if s := tree.Metrics().Snapshot(); s.Inserts != 1501 || s.InsertLatency.Count != 1 {
+		t.Error("wrong insert counts for batches:", s.Inserts, s.InsertLatency.Count)
+	}
+}
+
+func shuffledKeys(n int) []int {
+	return rand.New(rand.NewSource(int64(n))).Perm(n)
+}
+
+func Test_MetricsCounters(t *testing.T) {
+	tree := NewBTree(2)
+	if tree.Metrics() != nil || tree.Metrics().Snapshot().Inserts != 0 {
+		t.Error("metrics enabled by default")
+	}
+	m := tree.EnableMetrics("counters")
+	if tree.EnableMetrics("again") != m {
+		t.Error("enabling metrics twice replaced them")
+	}
+	for i := 0; i < 10; i++ {
+		tree.Insert(i, i)
+	}
+	tree.InsertWithTTL(10, 10, time.Hour)
+	tree.Search(3)
+	tree.Search(30)
+	tree.Remove(4)
+
+	s := m.Snapshot()
+	if s.Name != "counters" || s.Inserts != 11 || s.Searches != 2 || s.Removes != 1 {
+		t.Error("wrong counters:", s)
+	}
+	for _, latency := range []LatencySnapshot{s.InsertLatency, s.SearchLatency, s.RemoveLatency} {
+		total := uint64(0)
+		for _, count := range latency.Counts {
+			total += count
+		}
+		if len(latency.Counts) != len(latency.Bounds)+1 || total != latency.Count || latency.Sum <= 0 {
+			t.Error("wrong latency histogram:", latency)
+		}
+	}
+	if s.InsertLatency.Count != 11 {
+		t.Error("wrong number of insert latencies:", s.InsertLatency.Count)
+	}
+	checkGauges(t, tree)
+}
+
+func Test_MetricsPrometheus(t *testing.T) {
+	a, b := NewBTree(2), NewBTree(2)
+	for i := 0; i < 10; i++ {
+		a.Insert(i, nil)
+	}
+	recorder := httptest.NewRecorder()
+	PrometheusHandler(a.EnableMetrics("a"), b.EnableMetrics(`odd "name"`)).ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body := recorder.Body.String()
+
+	for _, line := range []string{
+		"# TYPE btree_inserts_total counter",
+		`btree_inserts_total{tree="a"} 0`,
+		`btree_size{tree="a"} 10`,
+		`btree_size{tree="odd \"name\""} 0`,
+		"# TYPE btree_search_duration_seconds histogram",
+		`btree_search_duration_seconds_bucket{tree="a",le="1e-07"} 0`,
+		`btree_search_duration_seconds_bucket{tree="a",le="+Inf"} 0`,
+		`btree_search_duration_seconds_count{tree="a"} 0`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Error("missing line from output:", line)
+		}
+	}
+	// Each metric only gets one header, however many trees there are.
+	if strings.Count(body, "# TYPE btree_size gauge") != 1 {
+		t.Error("repeated headers:", body)
+	}
+	if !strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Error("wrong content type:", recorder.Header().Get("Content-Type"))
+	}
+
+	// Buckets count everything up to their bound.
+	a.Search(1)
+	a.Search(2)
+	var out bytes.Buffer
+	if err := WritePrometheus(&out, a.Metrics()); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `btree_search_duration_seconds_bucket{tree="a",le="+Inf"} 2`+"\n") {
+		t.Error("buckets are not cumulative:", out.String())
+	}
+}
+
+func Test_MetricsExpvar(t *testing.T) {
+	tree := NewBTree(2)
+	tree.EnableMetrics("btree_expvar_test").Publish()
+	tree.Insert(1, nil)
+	var s MetricsSnapshot
+	if err := json.Unmarshal([]byte(expvar.Get("btree_expvar_test").String()), &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Inserts != 1 || s.Size != 1 {
+		t.Error("wrong published metrics:", s)
+	}
+}
@@ -0,0 +1,63 @@
+package BTree
+
+import (
+	"unsafe"
+)
+
+// A report on the shape of a tree and the space it uses, which can help
+// with choosing a dimension for a workload.
+type Stats struct {
+	// The number of levels in the tree, counting the root and the leaves.
+	Height int
+	// The number of nodes at each level, starting with the root.
+	NodesPerLevel []int
+	// The total number of nodes, and how many of them are leaves.
+	Nodes  int
+	Leaves int
+	// How full the nodes are, as a fraction of the maximum number of items
+	// they can hold. The root is left out of the minimum (unless it is the
+	// only node) since it is allowed to hold fewer items than the others.
+	AverageFill float64
+	MinFill     float64
+	// The number of item slots allocated across all of the nodes, and how
+	// many of them hold items. Each node allocates one more slot than its
+	// maximum size so that it can overflow before splitting.
+	AllocatedSlots int
+	UsedSlots      int
+	// A rough estimate of the memory used by the nodes. This does not
+	// include anything that the values in the tree point to.
+	EstimatedBytes int
+}
+
+// Walk the whole tree to build a report on its shape.
+func (tree *BTree) Stats() Stats {
+	stats := Stats{MinFill: 1}
+	totalFill := 0.0
+	level := []*node{tree.root}
+	for len(level) > 0 {
+		stats.Height++
+		stats.NodesPerLevel = append(stats.NodesPerLevel, len(level))
+		next := make([]*node, 0)
+		for _, n := range level {
+			fill := float64(n.currentSize) / float64(n.maxSize)
+			totalFill += fill
+			if (n != tree.root || n.isLeaf) && fill < stats.MinFill {
+				stats.MinFill = fill
+			}
+			stats.Nodes++
+			stats.AllocatedSlots += cap(n.items)
+			stats.UsedSlots += n.currentSize
+			stats.EstimatedBytes += int(unsafe.Sizeof(*n)) +
+				cap(n.items)*int(unsafe.Sizeof(item{})) +
+				cap(n.children)*int(unsafe.Sizeof(n))
+			if n.isLeaf {
+				stats.Leaves++
+			} else {
+				next = append(next, n.children[:n.currentSize+1]...)
+			}
+		}
+		level = next
+	}
+	stats.AverageFill = totalFill / float64(stats.Nodes)
+	return stats
+}
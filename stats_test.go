@@ -0,0 +1,66 @@
+package BTree
+
+import (
+	"testing"
+	"unsafe"
+)
+
+// Test the report for a small tree with a single split.
+func Test_Stats(t *testing.T) {
+	stats := buildTree("tree", []int{1, 2, 3, 4, 5}).Stats()
+	if stats.Height != 2 || len(stats.NodesPerLevel) != 2 ||
+		stats.NodesPerLevel[0] != 1 || stats.NodesPerLevel[1] != 2 {
+		t.Error("wrong levels:", stats.Height, stats.NodesPerLevel)
+	}
+	if stats.Nodes != 3 || stats.Leaves != 2 {
+		t.Error("wrong node counts:", stats.Nodes, stats.Leaves)
+	}
+	if stats.AverageFill != (0.25+0.5+0.5)/3 || stats.MinFill != 0.5 {
+		t.Error("wrong fill:", stats.AverageFill, stats.MinFill)
+	}
+	if stats.AllocatedSlots != 15 || stats.UsedSlots != 5 {
+		t.Error("wrong slot counts:", stats.AllocatedSlots, stats.UsedSlots)
+	}
+	nodeSize := int(unsafe.Sizeof(node{}))
+	itemSize := int(unsafe.Sizeof(item{}))
+	pointerSize := int(unsafe.Sizeof(&node{}))
+	if stats.EstimatedBytes != 3*nodeSize+15*itemSize+6*pointerSize {
+		t.Error("wrong memory estimate:", stats.EstimatedBytes)
+	}
+}
+
+// Test the report for an empty tree, where the root is the only node.
+func Test_StatsEmpty(t *testing.T) {
+	stats := NewBTree(3).Stats()
+	if stats.Height != 1 || stats.Nodes != 1 || stats.Leaves != 1 {
+		t.Error("wrong node counts:", stats.Height, stats.Nodes, stats.Leaves)
+	}
+	if stats.AverageFill != 0 || stats.MinFill != 0 {
+		t.Error("wrong fill:", stats.AverageFill, stats.MinFill)
+	}
+	if stats.AllocatedSlots != 7 || stats.UsedSlots != 0 {
+		t.Error("wrong slot counts:", stats.AllocatedSlots, stats.UsedSlots)
+	}
+}
+
+// Test that the report agrees with the other ways of measuring the tree.
+func Test_StatsMany(t *testing.T) {
+	tree := NewBTree(3)
+	for i := 0; i < 1000; i++ {
+		tree.Insert(i, i)
+	}
+	stats := tree.Stats()
+	if stats.Height != tree.Depth() || stats.UsedSlots != tree.Size() {
+		t.Error("wrong height or size:", stats.Height, stats.UsedSlots)
+	}
+	total := 0
+	for _, count := range stats.NodesPerLevel {
+		total += count
+	}
+	if total != stats.Nodes || stats.NodesPerLevel[stats.Height-1] != stats.Leaves {
+		t.Error("wrong node counts:", stats.NodesPerLevel, stats.Nodes, stats.Leaves)
+	}
+	if stats.MinFill < 0.5 || stats.AverageFill < stats.MinFill {
+		t.Error("wrong fill:", stats.AverageFill, stats.MinFill)
+	}
+}
@@ -0,0 +1,189 @@
+package BTree
+
+import (
+	"reflect"
+	"sync"
+	"time"
+)
+
+// The value stored for an item added by InsertWithTTL, which is the same
+// value held in the tree's expiry index.
+type expiring struct {
+	key   int
+	value interface{}
+	// When the item expires, in Unix nanoseconds.
+	deadline int
+}
+
+// Add a key value pair into the tree which expires once ttl has passed.
+// Expired items are not found by Search, Remove, Ascend or AscendRange,
+// and are left out by Merge, Intersect and Difference, but they stay in
+// the tree until Sweep removes them, so they are still counted by Size
+// and drawn by String and WriteDOT.
+func (tree *BTree) InsertWithTTL(key int, value interface{}, ttl time.Duration) {
+	start := tree.metrics.start()
+	if tree.expiry == nil {
+		tree.expiry = NewBTree(tree.dimension)
+	}
+	entry := &expiring{key, value, int(tree.clock().Add(ttl).UnixNano())}
+	tree.root.insert(item{key, entry}, nil)
+	tree.expiry.Insert(entry.deadline, entry)
+	tree.metrics.finish(insertOperation, start)
+}
+
+// Set the function used to find the current time when checking for expired
+// items, so that tests can control it. The default is time.Now.
+func (tree *BTree) SetClock(now func() time.Time) {
+	tree.now = now
+}
+
+// Remove all of the expired items from the tree, and return how many were
+// removed.
+func (tree *BTree) Sweep() int {
+	if tree.expiry == nil {
+		return 0
+	}
+	now := int(tree.clock().UnixNano())
+	removed := 0
+	for {
+		// The expiry index is sorted by deadline, so keep taking its first
+		// item until that has not expired.
+		first, ok := newIterator(tree.expiry.root).next()
+		if !ok || first.key > now {
+			return removed
+		}
+		entry := first.value.(*expiring)
+		tree.expiry.removeMatching(entry.deadline, entry)
+		tree.removeMatching(entry.key, entry)
+		removed++
+	}
+}
+
+// Sweep the tree every interval in the background, holding the lock while
+// doing so. The lock must be the one which guards all other use of the
+// tree. Returns a function which stops the sweeper and waits for it to
+// finish.
+func (tree *BTree) StartSweeper(interval time.Duration, lock sync.Locker) (stop func()) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				lock.Lock()
+				tree.Sweep()
+				lock.Unlock()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+func (tree *BTree) clock() time.Time {
+	if tree.now == nil {
+		return time.Now()
+	}
+	return tree.now()
+}
+
+// Unwrap a value stored in the tree, returning false if it has expired.
+func (tree *BTree) unwrap(value interface{}) (interface{}, bool) {
+	entry, ok := value.(*expiring)
+	if !ok {
+		return value, true
+	}
+	return entry.value, int(tree.clock().UnixNano()) < entry.deadline
+}
+
+// Walks through the items in a tree which have not expired, with their
+// values unwrapped, for reading a tree as a whole.
+type liveIterator struct {
+	tree  *BTree
+	items *iterator
+}
+
+// Start walking through the live items in the tree, in sorted order.
+func (tree *BTree) liveItems() *liveIterator {
+	return &liveIterator{tree, newIterator(tree.root)}
+}
+
+// Get the next live item, or false once there are none left.
+func (it *liveIterator) next() (item, bool) {
+	for next, ok := it.items.next(); ok; next, ok = it.items.next() {
+		if value, live := it.tree.unwrap(next.value); live {
+			return item{next.key, value}, true
+		}
+	}
+	return item{}, false
+}
+
+// Find the value of the first item with the key which has not expired,
+// for Search when the item it found first has.
+func (tree *BTree) searchLive(key int) interface{} {
+	it := newIteratorFrom(tree.root, key)
+	for next, ok := it.next(); ok && next.key == key; next, ok = it.next() {
+		if value, live := tree.unwrap(next.value); live {
+			return value
+		}
+	}
+	return nil
+}
+
+// Remove the item with the key whose value is the given one, leaving any
+// other items with the same key alone. This works for both the tree and
+// its expiry index, which hold the same entries. Returns false if it is
+// not found.
+func (tree *BTree) removeMatching(key int, value interface{}) bool {
+	found, i := tree.root.findMatching(key, value)
+	if found == nil {
+		return false
+	}
+	found.removeAt(i)
+	return true
+}
+
+// Find the item with the key below this node whose value is the given
+// one. Returns the node holding the item and its index in that node, or
+// nil if it is not found.
+func (n *node) findMatching(key int, value interface{}) (*node, int) {
+	for i := 0; i <= n.currentSize; i++ {
+		// Items with the key can be in any child between the separators
+		// on either side of it, including separators equal to the key.
+		if !n.isLeaf && (i == 0 || n.items[i-1].key <= key) && (i == n.currentSize || key <= n.items[i].key) {
+			if found, j := n.children[i].findMatching(key, value); found != nil {
+				return found, j
+			}
+		}
+		if i == n.currentSize || n.items[i].key > key {
+			break
+		}
+		if n.items[i].key == key && sameValue(n.items[i].value, value) {
+			return n, i
+		}
+	}
+	return nil, 0
+}
+
+// Whether two values held in the tree are the same. Values which can't be
+// compared with ==, such as slices and maps, are compared with
+// reflect.DeepEqual instead of panicking.
+func sameValue(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if reflect.TypeOf(a) != reflect.TypeOf(b) {
+		return false
+	}
+	if !reflect.TypeOf(a).Comparable() {
+		return reflect.DeepEqual(a, b)
+	}
+	return a == b
+}
@@ -0,0 +1,212 @@
+package BTree
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A clock for tests which only moves when told to.
+type fakeClock struct {
+	now time.Time
+}
+
+func (clock *fakeClock) Now() time.Time {
+	return clock.now
+}
+
+func newTTLTree() (*BTree, *fakeClock) {
+	clock := &fakeClock{time.Unix(1000, 0)}
+	tree := NewBTree(2)
+	tree.SetClock(clock.Now)
+	return tree, clock
+}
+
+func Test_TTLExpiry(t *testing.T) {
+	tree, clock := newTTLTree()
+	tree.Insert(1, "forever")
+	tree.InsertWithTTL(2, "short", time.Second)
+	tree.InsertWithTTL(3, "long", time.Minute)
+
+	if tree.Search(2) != "short" || tree.Search(3) != "long" {
+		t.Error("failed to find items before they expire")
+	}
+	clock.now = clock.now.Add(time.Second)
+	if value := tree.Search(2); value != nil {
+		t.Error("found an expired item:", value)
+	}
+	if tree.Search(1) != "forever" || tree.Search(3) != "long" {
+		t.Error("failed to find items which have not expired")
+	}
+
+	keys := make([]int, 0)
+	tree.Ascend(func(key int, value interface{}) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if !reflect.DeepEqual(keys, []int{1, 3}) {
+		t.Error("wrong keys from Ascend:", keys)
+	}
+	keys = keys[:0]
+	tree.AscendRange(2, 4, func(key int, value interface{}) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if !reflect.DeepEqual(keys, []int{3}) {
+		t.Error("wrong keys from AscendRange:", keys)
+	}
+
+	// Expired items stay in the tree until they are swept.
+	if tree.Size() != 3 {
+		t.Error("wrong size before sweeping:", tree.Size())
+	}
+	if removed := tree.Sweep(); removed != 1 || tree.Size() != 2 {
+		t.Error("wrong sweep:", removed, tree.Size())
+	}
+	clock.now = clock.now.Add(time.Hour)
+	if removed := tree.Sweep(); removed != 1 || tree.Size() != 1 || tree.expiry.Size() != 0 {
+		t.Error("wrong sweep:", removed, tree.Size(), tree.expiry.Size())
+	}
+	checkNode(t, tree.root, true)
+}
+
+// Test that expired duplicates are skipped in favor of live ones, and that
+// sweeping removes exactly the expired ones.
+func Test_TTLDuplicates(t *testing.T) {
+	tree, clock := newTTLTree()
+	for i := 0; i < 50; i++ {
+		tree.Insert(i, i)
+	}
+	for i := 0; i < 20; i++ {
+		tree.InsertWithTTL(25, "expired", time.Second)
+	}
+	tree.InsertWithTTL(25, "live", time.Hour)
+	clock.now = clock.now.Add(time.Minute)
+
+	if value := tree.Search(25); value != 25 && value != "live" {
+		t.Error("found an expired duplicate:", value)
+	}
+	if removed := tree.Sweep(); removed != 20 || tree.Size() != 51 {
+		t.Error("wrong sweep:", removed, tree.Size())
+	}
+	checkNode(t, tree.root, true)
+	found := make(map[interface{}]bool)
+	tree.AscendRange(25, 26, func(key int, value interface{}) bool {
+		found[value] = true
+		return true
+	})
+	if len(found) != 2 || !found[25] || !found["live"] {
+		t.Error("wrong items left after sweeping:", found)
+	}
+}
+
+func Test_TTLRemove(t *testing.T) {
+	tree, clock := newTTLTree()
+	tree.InsertWithTTL(1, "old", time.Second)
+	tree.InsertWithTTL(1, "new", time.Hour)
+	tree.InsertWithTTL(2, "two", time.Hour)
+	clock.now = clock.now.Add(time.Minute)
+
+	// The expired item is dropped on the way to the live one.
+	if value := tree.Remove(1); value != "new" {
+		t.Error("removed the wrong item:", value)
+	}
+	if tree.Size() != 1 || tree.expiry.Size() != 1 {
+		t.Error("removed items left behind:", tree.Size(), tree.expiry.Size())
+	}
+	if tree.Remove(1) != nil {
+		t.Error("removed an item twice")
+	}
+	if value := tree.Remove(2); value != "two" || tree.expiry.Size() != 0 {
+		t.Error("failed to remove an item with a ttl:", value, tree.expiry.Size())
+	}
+}
+
+// Test that combining trees leaves out expired items and passes on the
+// values of the live ones, rather than the wrappers they are stored in.
+func Test_TTLMerge(t *testing.T) {
+	tree, clock := newTTLTree()
+	tree.InsertWithTTL(1, "expired", time.Second)
+	tree.InsertWithTTL(2, "live", time.Hour)
+	tree.Insert(3, "plain")
+	clock.now = clock.now.Add(time.Minute)
+	other := buildTree("other", []int{1, 2, 4})
+
+	resolved := make([]interface{}, 0)
+	merged := Merge(tree, other, func(key int, a, b interface{}) interface{} {
+		resolved = append(resolved, a, b)
+		return a
+	})
+	checkKeys(t, merged, []int{1, 2, 3, 4})
+	if !reflect.DeepEqual(resolved, []interface{}{"live", "other: 2"}) {
+		t.Error("wrong values passed to resolve:", resolved)
+	}
+	if merged.Search(1) != "other: 1" || merged.Search(2) != "live" {
+		t.Error("wrong merged values:", merged.Search(1), merged.Search(2))
+	}
+	if value := merged.Remove(2); value != "live" {
+		t.Error("removed the wrong item from a merged tree:", value)
+	}
+
+	intersection := Intersect(tree, other, func(key int, a, b interface{}) interface{} { return a })
+	checkKeys(t, intersection, []int{2})
+	if intersection.Search(2) != "live" {
+		t.Error("wrong value in the intersection:", intersection.Search(2))
+	}
+	checkKeys(t, Difference(tree, other), []int{3})
+	checkKeys(t, Difference(other, tree), []int{1, 4})
+	if tree.Size() != 3 || tree.expiry.Size() != 2 {
+		t.Error("combining trees changed their inputs:", tree.Size(), tree.expiry.Size())
+	}
+}
+
+// Test that a transaction uses the tree's clock once it has taken its own
+// copy of the tree.
+func Test_TTLTransaction(t *testing.T) {
+	tree, clock := newTTLTree()
+	tree.InsertWithTTL(1, "live", time.Minute)
+	tree.InsertWithTTL(3, "three", 2*time.Hour)
+	tx := tree.Begin()
+	tx.Insert(2, "tx")
+	if tx.Search(1) != "live" {
+		t.Error("transaction does not see a live item:", tx.Search(1))
+	}
+	clock.now = clock.now.Add(time.Hour)
+	if tx.Search(1) != nil || tx.Remove(1) != nil {
+		t.Error("transaction sees an expired item:", tx.Search(1))
+	}
+
+	// Committing a removal takes the item out of the expiry index too.
+	if value := tx.Remove(3); value != "three" {
+		t.Error("removed the wrong item in a transaction:", value)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Error("commit failed:", err)
+	}
+	if tree.Search(3) != nil || tree.Search(2) != "tx" || tree.expiry.Size() != 1 {
+		t.Error("wrong items after committing:", tree.Search(3), tree.Search(2), tree.expiry.Size())
+	}
+}
+
+func Test_TTLSweeper(t *testing.T) {
+	tree, clock := newTTLTree()
+	var lock sync.Mutex
+	lock.Lock()
+	tree.InsertWithTTL(1, "one", time.Second)
+	clock.now = clock.now.Add(time.Minute)
+	lock.Unlock()
+
+	stop := tree.StartSweeper(time.Millisecond, &lock)
+	defer stop()
+	for i := 0; i < 1000; i++ {
+		lock.Lock()
+		size := tree.Size()
+		lock.Unlock()
+		if size == 0 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Error("the sweeper did not remove the expired item")
+}
@@ -0,0 +1,203 @@
+package BTree
+
+import (
+	"errors"
+)
+
+// Returned when committing or rolling back a transaction which has already
+// been committed or rolled back.
+var ErrTxDone = errors.New("BTree: transaction has already been committed or rolled back")
+
+// Returned when rolling back to a savepoint which has already been
+// released.
+var ErrInvalidSavepoint = errors.New("BTree: savepoint has already been released")
+
+// A point inside a transaction which later changes can be rolled back to,
+// without losing the changes made before it.
+type Savepoint int
+
+// A group of changes to a tree which are either all applied by Commit or
+// all thrown away by Rollback. Searches through the transaction see its
+// own changes, while the tree does not see any of them until Commit.
+//
+// The first change made in a transaction takes a private copy of the tree
+// to apply its changes to, so this costs time and memory in proportion to
+// the size of the tree. Until then searches read straight from the tree.
+// Rolling back to a savepoint rebuilds the copy, so it has the same cost.
+// The copy is made node by node, so that it picks the same item as the
+// tree would out of several with the same key.
+type Tx struct {
+	tree *BTree
+	// A copy of the tree's root taken when the first change was made,
+	// which the private copy is rebuilt from when rolling back to a
+	// savepoint.
+	base *node
+	// The private copy of the tree with the changes applied to it, or nil
+	// if no changes have been made yet.
+	view *BTree
+	// The changes made so far, in order, to replay on Commit.
+	ops []txOp
+	// The savepoints which have not been released, oldest first.
+	savepoints []savepoint
+	// The handle given to the next savepoint. Handles are never reused,
+	// so that a released savepoint can't be mistaken for a newer one.
+	nextSavepoint Savepoint
+	// Set once the transaction is committed or rolled back.
+	done bool
+}
+
+// A savepoint along with the number of changes made before it.
+type savepoint struct {
+	handle Savepoint
+	ops    int
+}
+
+// A single change made inside a transaction. For a removal the value is
+// the one stored in the tree for the item which was removed, so that the
+// same item is removed on Commit.
+type txOp struct {
+	// Is this a call to Remove (rather than Insert)?
+	remove bool
+	key    int
+	value  interface{}
+}
+
+// Start a new transaction on the tree.
+func (tree *BTree) Begin() *Tx {
+	return &Tx{tree, nil, nil, nil, nil, 0, false}
+}
+
+// Add a key value pair into the tree when the transaction commits.
+func (tx *Tx) Insert(key int, value interface{}) {
+	tx.write()
+	op := txOp{false, key, value}
+	op.apply(tx.view)
+	tx.ops = append(tx.ops, op)
+}
+
+// Remove the first item with the given key as of this point in the
+// transaction, and return its value, or nil if the key is not found.
+// Commit removes that same item from the tree, if it is still there,
+// rather than whichever item has the key by then. Nothing is removed on
+// Commit if the key was not found.
+func (tx *Tx) Remove(key int) interface{} {
+	tx.write()
+	stored, ok := tx.view.removeFirst(key)
+	if !ok {
+		return nil
+	}
+	tx.ops = append(tx.ops, txOp{true, key, stored})
+	value, _ := tx.view.unwrap(stored)
+	return value
+}
+
+// Find the value of the first item with the key, including the changes
+// made so far in this transaction.
+func (tx *Tx) Search(key int) interface{} {
+	tx.checkOpen()
+	if tx.view == nil {
+		return tx.tree.Search(key)
+	}
+	return tx.view.Search(key)
+}
+
+// Mark the current point in the transaction so that the changes made
+// after it can be undone with RollbackTo. Savepoints stack, so rolling
+// back to one releases all of the savepoints made after it.
+func (tx *Tx) Savepoint() Savepoint {
+	tx.checkOpen()
+	sp := tx.nextSavepoint
+	tx.nextSavepoint++
+	tx.savepoints = append(tx.savepoints, savepoint{sp, len(tx.ops)})
+	return sp
+}
+
+// Undo the changes made since the savepoint, keeping the ones made before
+// it. The savepoint itself stays in place and can be rolled back to again.
+func (tx *Tx) RollbackTo(sp Savepoint) error {
+	if tx.done {
+		return ErrTxDone
+	}
+	i := 0
+	for i < len(tx.savepoints) && tx.savepoints[i].handle != sp {
+		i++
+	}
+	if i == len(tx.savepoints) {
+		return ErrInvalidSavepoint
+	}
+	tx.ops = tx.ops[:tx.savepoints[i].ops]
+	tx.savepoints = tx.savepoints[:i+1]
+	if tx.view != nil {
+		tx.view = tx.copyBase()
+		for _, op := range tx.ops {
+			op.apply(tx.view)
+		}
+	}
+	return nil
+}
+
+// Apply all of the changes in the transaction to the tree.
+func (tx *Tx) Commit() error {
+	if tx.done {
+		return ErrTxDone
+	}
+	for _, op := range tx.ops {
+		op.apply(tx.tree)
+	}
+	tx.finish()
+	return nil
+}
+
+// Throw away all of the changes in the transaction.
+func (tx *Tx) Rollback() error {
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.finish()
+	return nil
+}
+
+// Get ready to make a change to the private copy of the tree, making the
+// copy first if needed.
+func (tx *Tx) write() {
+	tx.checkOpen()
+	if tx.view == nil {
+		tx.base = tx.tree.root.clone(nil)
+		tx.view = tx.copyBase()
+	}
+}
+
+// Make a new private copy of the tree from the one taken by the first
+// change. The copy tells the time with the tree's clock, so that items
+// added by InsertWithTTL expire at the same time in both.
+func (tx *Tx) copyBase() *BTree {
+	return &BTree{tx.tree.dimension, tx.base.clone(nil), nil, tx.tree.clock, nil, nil}
+}
+
+// Release everything held by the transaction, including its savepoints,
+// since it can't be used again.
+func (tx *Tx) finish() {
+	tx.base = nil
+	tx.view = nil
+	tx.ops = nil
+	tx.savepoints = nil
+	tx.done = true
+}
+
+// Changes can't be made to a finished transaction, and there is nowhere
+// for Insert to report that, so this panics.
+func (tx *Tx) checkOpen() {
+	if tx.done {
+		panic(ErrTxDone)
+	}
+}
+
+// Apply the change to a tree. A removal only removes the item it removed
+// inside the transaction, and does nothing if it is no longer there.
+func (op txOp) apply(tree *BTree) {
+	if op.remove {
+		tree.removeExact(op.key, op.value)
+	} else {
+		tree.Insert(op.key, op.value)
+	}
+}
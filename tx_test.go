@@ -0,0 +1,215 @@
+package BTree
+
+import (
+	"testing"
+)
+
+// Test that committed changes show up in the tree.
+func Test_TxCommit(t *testing.T) {
+	tree := buildTree("tree", []int{1, 2, 3})
+	tx := tree.Begin()
+	tx.Insert(4, "tx: 4")
+	if tx.Remove(2) != "tree: 2" {
+		t.Error("Wrong value removed in transaction")
+	}
+
+	// The transaction sees its own changes but the tree does not.
+	if tx.Search(4) != "tx: 4" || tx.Search(2) != nil {
+		t.Error("transaction does not see its changes:", tx.Search(4), tx.Search(2))
+	}
+	if tree.Search(4) != nil || tree.Search(2) != "tree: 2" {
+		t.Error("tree sees uncommitted changes:", tree.Search(4), tree.Search(2))
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Error("commit failed:", err)
+	}
+	checkKeys(t, tree, []int{1, 3, 4})
+	if tree.Search(4) != "tx: 4" {
+		t.Error("Wrong value for key 4:", tree.Search(4))
+	}
+	if tx.Commit() != ErrTxDone || tx.Rollback() != ErrTxDone {
+		t.Error("transaction finished twice")
+	}
+}
+
+// Test that rolled back changes never show up in the tree.
+func Test_TxRollback(t *testing.T) {
+	tree := buildTree("tree", []int{1, 2, 3})
+	tx := tree.Begin()
+	for i := 10; i < 100; i++ {
+		tx.Insert(i, "tx")
+	}
+	tx.Remove(1)
+	if err := tx.Rollback(); err != nil {
+		t.Error("rollback failed:", err)
+	}
+	checkKeys(t, tree, []int{1, 2, 3})
+	if tx.Rollback() != ErrTxDone || tx.Commit() != ErrTxDone {
+		t.Error("transaction finished twice")
+	}
+
+	defer func() {
+		if recover() != ErrTxDone {
+			t.Error("insert into finished transaction did not panic")
+		}
+	}()
+	tx.Insert(5, "too late")
+}
+
+// Test that a transaction reads through to the tree until it makes a
+// change, and that changes made to the tree outside of the transaction
+// are kept when it commits.
+func Test_TxWithOtherChanges(t *testing.T) {
+	tree := buildTree("tree", []int{1, 2, 3})
+	tx := tree.Begin()
+	tree.Insert(5, "tree: 5")
+	if tx.Search(5) != "tree: 5" {
+		t.Error("transaction does not see the tree:", tx.Search(5))
+	}
+	tx.Insert(6, "tx: 6")
+	tree.Insert(7, "tree: 7")
+	if tx.Search(7) != nil {
+		t.Error("transaction sees changes made after it started writing")
+	}
+	tx.Commit()
+	checkKeys(t, tree, []int{1, 2, 3, 5, 6, 7})
+	checkNode(t, tree.root, true)
+}
+
+// Test undoing part of a transaction with savepoints.
+func Test_TxSavepoints(t *testing.T) {
+	tree := buildTree("tree", []int{1, 2, 3})
+	tx := tree.Begin()
+	tx.Insert(4, "tx: 4")
+	first := tx.Savepoint()
+	tx.Insert(5, "tx: 5")
+	tx.Remove(1)
+	second := tx.Savepoint()
+	tx.Insert(6, "tx: 6")
+
+	if err := tx.RollbackTo(second); err != nil {
+		t.Error("rollback to savepoint failed:", err)
+	}
+	if tx.Search(6) != nil || tx.Search(5) != "tx: 5" || tx.Search(1) != nil {
+		t.Error("wrong changes undone:", tx.Search(6), tx.Search(5), tx.Search(1))
+	}
+
+	// Rolling back to the first savepoint releases the second one.
+	if err := tx.RollbackTo(first); err != nil {
+		t.Error("rollback to savepoint failed:", err)
+	}
+	if tx.Search(5) != nil || tx.Search(1) != "tree: 1" || tx.Search(4) != "tx: 4" {
+		t.Error("wrong changes undone:", tx.Search(5), tx.Search(1), tx.Search(4))
+	}
+	if tx.RollbackTo(second) != ErrInvalidSavepoint {
+		t.Error("rolled back to a released savepoint")
+	}
+
+	// The first savepoint can be used again.
+	tx.Insert(7, "tx: 7")
+	if err := tx.RollbackTo(first); err != nil {
+		t.Error("rollback to savepoint failed:", err)
+	}
+	tx.Insert(8, "tx: 8")
+	if err := tx.Commit(); err != nil {
+		t.Error("commit failed:", err)
+	}
+	checkKeys(t, tree, []int{1, 2, 3, 4, 8})
+	if tx.RollbackTo(first) != ErrTxDone {
+		t.Error("rolled back to a savepoint after commit")
+	}
+}
+
+// Test that a released savepoint stays released after newer savepoints
+// are made, rather than standing in for one of them.
+func Test_TxReleasedSavepoint(t *testing.T) {
+	tree := buildTree("tree", []int{1})
+	tx := tree.Begin()
+	first := tx.Savepoint()
+	tx.Insert(2, "tx: 2")
+	second := tx.Savepoint()
+	tx.RollbackTo(first)
+	tx.Insert(3, "tx: 3")
+	third := tx.Savepoint()
+	tx.Insert(4, "tx: 4")
+
+	if second == third {
+		t.Error("released savepoint handle was reused:", second)
+	}
+	if tx.RollbackTo(second) != ErrInvalidSavepoint {
+		t.Error("rolled back to a released savepoint")
+	}
+	if tx.Search(3) != "tx: 3" || tx.Search(4) != "tx: 4" {
+		t.Error("changes undone by a released savepoint:", tx.Search(3), tx.Search(4))
+	}
+	if err := tx.RollbackTo(third); err != nil || tx.Search(4) != nil || tx.Search(3) != "tx: 3" {
+		t.Error("rollback to the newest savepoint failed:", err, tx.Search(4), tx.Search(3))
+	}
+}
+
+// Test savepoints made before the transaction has changed anything.
+func Test_TxSavepointBeforeChanges(t *testing.T) {
+	tree := buildTree("tree", []int{1, 2, 3})
+	tx := tree.Begin()
+	sp := tx.Savepoint()
+	tx.Remove(2)
+	tx.Insert(10, "tx: 10")
+	tx.RollbackTo(sp)
+	if tx.Search(2) != "tree: 2" || tx.Search(10) != nil {
+		t.Error("changes were not undone:", tx.Search(2), tx.Search(10))
+	}
+	tx.Commit()
+	checkKeys(t, tree, []int{1, 2, 3})
+}
+
+// Test that a removal commits the same item it removed inside the
+// transaction, when there are several items with the key.
+func Test_TxDuplicateKeys(t *testing.T) {
+	tree := NewBTree(1)
+	for i := 0; i < 5; i++ {
+		tree.Insert(5, i)
+	}
+	tx := tree.Begin()
+	removed := tx.Remove(5)
+	if removed != tree.Search(5) {
+		t.Error("transaction removed a different item than the tree would:", removed, tree.Search(5))
+	}
+	if err := tx.Commit(); err != nil {
+		t.Error("commit failed:", err)
+	}
+	left := make([]interface{}, 0)
+	tree.Ascend(func(key int, value interface{}) bool {
+		left = append(left, value)
+		return true
+	})
+	if len(left) != 4 {
+		t.Error("wrong number of items left:", left)
+	}
+	for _, value := range left {
+		if value == removed {
+			t.Error("committed the removal of a different item:", removed, left)
+		}
+	}
+	checkNode(t, tree.root, true)
+}
+
+// Test that a removal commits only what it found inside the transaction,
+// whatever has been added to the tree since.
+func Test_TxRemoveAfterOtherChanges(t *testing.T) {
+	tree := buildTree("tree", []int{1, 2, 3})
+	tx := tree.Begin()
+	if tx.Remove(7) != nil {
+		t.Error("removed a key which is not there")
+	}
+	tx.Remove(2)
+	tree.Insert(7, "tree: 7")
+	tree.Insert(2, "tree: 2 again")
+	if err := tx.Commit(); err != nil {
+		t.Error("commit failed:", err)
+	}
+	checkKeys(t, tree, []int{1, 2, 3, 7})
+	if tree.Search(2) != "tree: 2 again" || tree.Search(7) != "tree: 7" {
+		t.Error("committed the removal of the wrong items:", tree.Search(2), tree.Search(7))
+	}
+}